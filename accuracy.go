@@ -0,0 +1,248 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The Pomodoro Technique suggests breaking down any task estimated above 5 to 7
+// pomodoros; accuracy flags tasks past either end of that rule
+const (
+	largeTaskPomodoros    = 5
+	oversizeTaskPomodoros = 7
+)
+
+// TaskAccuracy compares the estimate of a finished task with what it took
+type TaskAccuracy struct {
+	ID       int     `json:"id"`
+	Name     string  `json:"name"`
+	Project  string  `json:"project"`
+	Estimate int     `json:"estimate"`
+	Actual   int     `json:"actual"`
+	Ratio    float64 `json:"ratio"` // actual / estimate, above 1 when underestimated
+	Finished string  `json:"finished"`
+	Flag     string  `json:"flag"` // "large" above 5 pomodoros, "break it down" above 7
+}
+
+// AccuracySummary sums up the estimates and actuals of a group of tasks
+type AccuracySummary struct {
+	Tasks    int
+	Estimate int
+	Actual   int
+	Over     int // took more than estimated
+	OnTarget int
+	Under    int // took less than estimated
+}
+
+// Ratio is the actual pomodoros of every task over their estimates
+func (s AccuracySummary) Ratio() float64 {
+	if s.Estimate == 0 {
+		return 0
+	}
+	return float64(s.Actual) / float64(s.Estimate)
+}
+
+func (s *AccuracySummary) add(task TaskAccuracy) {
+	s.Tasks++
+	s.Estimate += task.Estimate
+	s.Actual += task.Actual
+	switch {
+	case task.Actual > task.Estimate:
+		s.Over++
+	case task.Actual < task.Estimate:
+		s.Under++
+	default:
+		s.OnTarget++
+	}
+}
+
+// accuracyBuckets label the histogram of actual / estimate
+var accuracyBuckets = []string{"≤ 0.5×", "< 1×", "= 1×", "≤ 1.5×", "≤ 2×", "> 2×"}
+
+// accuracyBucket returns the histogram bucket a ratio falls in
+func accuracyBucket(ratio float64) int {
+	switch {
+	case ratio <= 0.5:
+		return 0
+	case ratio < 1:
+		return 1
+	case ratio == 1:
+		return 2
+	case ratio <= 1.5:
+		return 3
+	case ratio <= 2:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// accuracyFlag marks tasks that should have been broken down
+func accuracyFlag(estimate, actual int) string {
+	size := max(estimate, actual)
+	switch {
+	case size > oversizeTaskPomodoros:
+		return "break it down"
+	case size > largeTaskPomodoros:
+		return "large"
+	default:
+		return ""
+	}
+}
+
+// getTaskAccuracy returns the done tasks with an estimate that were finished
+// between from and to (inclusive, formatted 2006-01-02), most recent first. A
+// task counts as finished on the last day it was tracked, or the day it was last
+// updated when it never was.
+func getTaskAccuracy(db *sql.DB, from, to string, project string) ([]TaskAccuracy, error) {
+	query := `
+    SELECT t.id, t.name, COALESCE(p.name, ''), t.estimate, t.actual,
+        (SELECT MAX(CAST(tr.date AS TEXT)) FROM task_tracking tr WHERE tr.task_id = t.id), t.updated_at
+    FROM tasks t
+    LEFT JOIN projects p ON p.id = t.project_id
+    WHERE t.done = 1 AND t.estimate > 0 AND (? = '' OR p.name = ?)`
+
+	rows, err := db.Query(query, project, project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task accuracy: %v", err)
+	}
+	defer rows.Close()
+
+	var tasks []TaskAccuracy
+	for rows.Next() {
+		var task TaskAccuracy
+		var tracked sql.NullString
+		var updatedAt time.Time
+		if err := rows.Scan(&task.ID, &task.Name, &task.Project, &task.Estimate, &task.Actual, &tracked, &updatedAt); err != nil {
+			return nil, err
+		}
+		// updated_at is stored in UTC, so its day is only known in local time
+		task.Finished = tracked.String
+		if !tracked.Valid {
+			task.Finished = formatDate(updatedAt.Local())
+		}
+		if task.Finished < from || task.Finished > to {
+			continue
+		}
+		task.Ratio = float64(task.Actual) / float64(task.Estimate)
+		task.Flag = accuracyFlag(task.Estimate, task.Actual)
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].Finished != tasks[j].Finished {
+			return tasks[i].Finished > tasks[j].Finished
+		}
+		return tasks[i].ID > tasks[j].ID
+	})
+	return tasks, nil
+}
+
+// accuracyRange is the range of the accuracy report: the last 12 weeks unless
+// --from and --to say otherwise
+func accuracyRange(options reportOptions) (string, string) {
+	startOfWeek, endOfWeek := getWeek(options.now)
+	start, end := reportPeriod(options, startOfWeek.AddDate(0, 0, -7*11), endOfWeek)
+	return formatDate(start), formatDate(end)
+}
+
+// weeklyAccuracy groups the tasks by the week they were finished in, oldest
+// week first
+func weeklyAccuracy(tasks []TaskAccuracy) ([]string, map[string]AccuracySummary) {
+	weeks := map[string]AccuracySummary{}
+	for _, task := range tasks {
+		finished, err := time.ParseInLocation("2006-01-02", task.Finished, time.Local)
+		if err != nil {
+			continue
+		}
+		start, _ := getWeek(finished)
+		week := weeks[formatDate(start)]
+		week.add(task)
+		weeks[formatDate(start)] = week
+	}
+
+	var starts []string
+	for start := range weeks {
+		starts = append(starts, start)
+	}
+	sort.Strings(starts)
+	return starts, weeks
+}
+
+// generateAccuracyReport compares the estimates of finished tasks with their
+// actual pomodoros: per task, overall, as a histogram and week by week
+func generateAccuracyReport(options reportOptions) {
+	from, to := accuracyRange(options)
+	tasks, err := getTaskAccuracy(db, from, to, options.project)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if printRecords(tasks) {
+		return
+	}
+
+	fmt.Println("╔════════════════════════════════════════════════════════════════════════════════════╗ ")
+	fmt.Printf("║ %-82s ║\n", fmt.Sprintf("Estimation Accuracy (%s to %s)", from, to))
+	fmt.Println("╠════════════════════════════════════════════════════════════════════════════════════╣ ")
+	if len(tasks) == 0 {
+		fmt.Printf("║ %-82s ║\n", "No finished tasks with an estimate.")
+		fmt.Println("╚════════════════════════════════════════════════════════════════════════════════════╝ ")
+		return
+	}
+
+	fmt.Printf("║ %-4s %-43s %5s %5s %6s  %-13s ║\n", "ID", "Task", "Est.", "Act.", "Ratio", "")
+	fmt.Println("╠════════════════════════════════════════════════════════════════════════════════════╣ ")
+	var total AccuracySummary
+	histogram := make([]int, len(accuracyBuckets))
+	for _, task := range tasks {
+		total.add(task)
+		histogram[accuracyBucket(task.Ratio)]++
+		fmt.Printf("║ %-4d %-43s %5d %5d %5.2f×  %-13s ║\n", task.ID, truncate(task.Name, 43), task.Estimate, task.Actual, task.Ratio, task.Flag)
+	}
+
+	fmt.Println("╠════════════════════════════════════════════════════════════════════════════════════╣ ")
+	fmt.Printf("║ %-82s ║\n", fmt.Sprintf("%d tasks estimated at %d pomodoros took %d: %.2f× the estimate",
+		total.Tasks, total.Estimate, total.Actual, total.Ratio()))
+	fmt.Printf("║ %-82s ║\n", fmt.Sprintf("%d underestimated, %d on target, %d overestimated",
+		total.Over, total.OnTarget, total.Under))
+
+	fmt.Println("╠════════════════════════════════════════════════════════════════════════════════════╣ ")
+	fmt.Printf("║ %-82s ║\n", "Actual / estimate")
+	for i, label := range accuracyBuckets {
+		width := min(histogram[i], 70)
+		fmt.Printf("║ %-6s %4d %s%s ║\n", label, histogram[i],
+			colorize(strings.Repeat("▓", width), "32"), strings.Repeat(" ", 70-width))
+	}
+
+	fmt.Println("╠════════════════════════════════════════════════════════════════════════════════════╣ ")
+	fmt.Printf("║ %-10s %5s %5s %5s %6s %-46s ║\n", "Week", "Tasks", "Est.", "Act.", "Ratio", "")
+	starts, weeks := weeklyAccuracy(tasks)
+	for _, start := range starts {
+		week := weeks[start]
+		// the bar is 20 characters at 1×, so an estimate that was right lines up
+		width := min(int(week.Ratio()*20+0.5), 46)
+		first, _ := time.ParseInLocation("2006-01-02", start, time.Local)
+		fmt.Printf("║ %-10s %5d %5d %5d %5.2f× %s%s ║\n", isoWeek(first), week.Tasks, week.Estimate, week.Actual, week.Ratio(),
+			colorize(strings.Repeat("▓", width), "32"), strings.Repeat(" ", 46-width))
+	}
+
+	var flagged []string
+	for _, task := range tasks {
+		if task.Flag == "break it down" {
+			flagged = append(flagged, fmt.Sprintf("%d", task.ID))
+		}
+	}
+	if len(flagged) > 0 {
+		fmt.Println("╠════════════════════════════════════════════════════════════════════════════════════╣ ")
+		fmt.Printf("║ %-82s ║\n", truncate(fmt.Sprintf("Above %d pomodoros, break these down next time: %s",
+			oversizeTaskPomodoros, strings.Join(flagged, ", ")), 82))
+	}
+	fmt.Println("╚════════════════════════════════════════════════════════════════════════════════════╝ ")
+}
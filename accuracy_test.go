@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTaskAccuracy(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	// estimate, actual and the day each task was last tracked
+	for _, task := range []struct {
+		estimate, actual int
+		date             string
+	}{
+		{2, 2, "2024-09-16"},
+		{4, 6, "2024-09-18"},
+		{6, 3, "2024-09-24"},
+		{8, 9, "2024-09-25"},
+		{3, 1, "2024-08-01"}, // before the range
+	} {
+		id, err := createTask(db, Task{Name: "Task", Estimate: task.estimate, Done: true}, time.Now())
+		if err != nil {
+			t.Fatalf("failed to add task: %v", err)
+		}
+		if _, err := db.Exec(`UPDATE tasks SET actual = ? WHERE id = ?`, task.actual, id); err != nil {
+			t.Fatalf("failed to set actual: %v", err)
+		}
+		if err := insertTrackingTask(id, task.date, 20); err != nil {
+			t.Fatalf("failed to track task: %v", err)
+		}
+	}
+	// unfinished tasks are left out
+	if _, err := createTask(db, Task{Name: "Task in progress", Estimate: 1}, time.Now()); err != nil {
+		t.Fatalf("failed to add task: %v", err)
+	}
+
+	tasks, err := getTaskAccuracy(db, "2024-09-01", "2024-09-30", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tasks) != 4 || tasks[0].ID != 4 || tasks[0].Finished != "2024-09-25" {
+		t.Fatalf("expected the 4 tasks finished in September, latest first, got %+v", tasks)
+	}
+	if tasks[0].Ratio != 1.125 || tasks[0].Flag != "break it down" || tasks[1].Flag != "large" || tasks[3].Flag != "" {
+		t.Errorf("unexpected ratios or flags: %+v", tasks)
+	}
+
+	var total AccuracySummary
+	for _, task := range tasks {
+		total.add(task)
+	}
+	if total.Ratio() != 1 || total.Over != 2 || total.OnTarget != 1 || total.Under != 1 {
+		t.Errorf("unexpected summary: %+v", total)
+	}
+
+	starts, weeks := weeklyAccuracy(tasks)
+	if len(starts) != 2 || starts[0] != "2024-09-15" || weeks["2024-09-15"].Tasks != 2 || weeks["2024-09-22"].Actual != 12 {
+		t.Errorf("unexpected weekly trend: %v %+v", starts, weeks)
+	}
+}
+
+func TestAccuracyBucket(t *testing.T) {
+	for ratio, want := range map[float64]string{0.25: "≤ 0.5×", 0.75: "< 1×", 1: "= 1×", 1.5: "≤ 1.5×", 2: "≤ 2×", 3: "> 2×"} {
+		if got := accuracyBuckets[accuracyBucket(ratio)]; got != want {
+			t.Errorf("expected %v to fall in %s, got %s", ratio, want, got)
+		}
+	}
+}
+
+func TestAccuracyRange(t *testing.T) {
+	from, to := accuracyRange(reportOptions{now: time.Date(2024, time.September, 25, 12, 0, 0, 0, time.Local)})
+	if from != "2024-07-07" || to != "2024-09-28" {
+		t.Errorf("expected the last 12 weeks, got %s to %s", from, to)
+	}
+	from, _ = accuracyRange(reportOptions{from: "2024-01-01", now: time.Now()})
+	if from != "2024-01-01" {
+		t.Errorf("expected --from to win, got %s", from)
+	}
+}
@@ -0,0 +1,553 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// archiveFormat identifies a tomatillo export document and archiveVersion is the
+// version of its layout. Bump the version whenever the layout changes in a way
+// older versions of tomatillo cannot read.
+//
+// Version 2 timestamps are in UTC. Version 1 wrote the times of tasks and
+// projects in local time without an offset.
+const (
+	archiveFormat  = "tomatillo-export"
+	archiveVersion = 2
+)
+
+// Archive is a portable copy of the whole database. Timestamps are kept exactly
+// as they are stored so a round trip does not shift them.
+type Archive struct {
+	Format        string            `json:"format"`
+	Version       int               `json:"version"`
+	SchemaVersion int               `json:"schema_version"`
+	ExportedAt    time.Time         `json:"exported_at"`
+	Projects      []ArchiveProject  `json:"projects"`
+	Tasks         []ArchiveTask     `json:"tasks"`
+	Tracking      []ArchiveTracking `json:"tracking"`
+	Sessions      []ArchiveSession  `json:"sessions"`
+	Breaks        []ArchiveBreak    `json:"breaks"`
+	Settings      map[string]string `json:"settings"`
+}
+
+type ArchiveProject struct {
+	Name      string `json:"name"`
+	Archived  bool   `json:"archived"`
+	CreatedAt string `json:"created_at"`
+}
+
+type ArchiveTask struct {
+	ID        int      `json:"id"`
+	Name      string   `json:"name"`
+	Estimate  int      `json:"estimate"`
+	Actual    int      `json:"actual"`
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+	Done      bool     `json:"done"`
+	Project   string   `json:"project"`
+	Tags      []string `json:"tags"`
+	DueDate   string   `json:"due_date,omitempty"`
+	Notes     string   `json:"notes,omitempty"`
+}
+
+type ArchiveTracking struct {
+	TaskID    int    `json:"task_id"`
+	Date      string `json:"date"`
+	HalfHour  int    `json:"half_hour"`
+	TaskName  string `json:"task_name"`
+	Status    string `json:"status"`
+	StartedAt string `json:"started_at,omitempty"`
+	EndedAt   string `json:"ended_at,omitempty"`
+}
+
+type ArchiveSession struct {
+	TaskID         int    `json:"task_id"`
+	StartedAt      string `json:"started_at"`
+	EndedAt        string `json:"ended_at"`
+	PlannedSeconds int    `json:"planned_seconds"`
+	Outcome        string `json:"outcome"`
+	Interruption   string `json:"interruption"`
+	Reason         string `json:"reason"`
+}
+
+type ArchiveBreak struct {
+	Kind           string `json:"kind"`
+	Date           string `json:"date"`
+	HalfHour       int    `json:"half_hour"`
+	StartedAt      string `json:"started_at"`
+	EndedAt        string `json:"ended_at"`
+	PlannedSeconds int    `json:"planned_seconds"`
+}
+
+// ImportSummary counts what an import added, what it already had and what clashed
+type ImportSummary struct {
+	Tasks             int
+	DuplicateTasks    int
+	Tracking          int
+	DuplicateTracking int
+	Conflicts         []string
+	Sessions          int
+	Breaks            int
+}
+
+// exportArchive reads the whole database into an Archive exported at now
+func exportArchive(db *sql.DB, now time.Time) (Archive, error) {
+	version, err := getSchemaVersion(db)
+	if err != nil {
+		return Archive{}, err
+	}
+
+	archive := Archive{
+		Format:        archiveFormat,
+		Version:       archiveVersion,
+		SchemaVersion: version,
+		ExportedAt:    now,
+		Projects:      []ArchiveProject{},
+		Tasks:         []ArchiveTask{},
+		Tracking:      []ArchiveTracking{},
+		Sessions:      []ArchiveSession{},
+		Breaks:        []ArchiveBreak{},
+		Settings:      map[string]string{},
+	}
+
+	err = queryRows(db, `SELECT name, archived, CAST(COALESCE(created_at, '') AS TEXT) FROM projects ORDER BY id`,
+		func(rows *sql.Rows) error {
+			var project ArchiveProject
+			if err := rows.Scan(&project.Name, &project.Archived, &project.CreatedAt); err != nil {
+				return err
+			}
+			archive.Projects = append(archive.Projects, project)
+			return nil
+		})
+	if err != nil {
+		return Archive{}, fmt.Errorf("failed to export projects: %v", err)
+	}
+
+	err = queryRows(db, `
+    SELECT t.id, t.name, t.estimate, COALESCE(t.actual, 0), CAST(COALESCE(t.created_at, '') AS TEXT),
+        CAST(COALESCE(t.updated_at, '') AS TEXT), COALESCE(t.done, 0), COALESCE(p.name, ''),
+        CAST(COALESCE(t.due_date, '') AS TEXT), COALESCE(t.notes, '')
+    FROM tasks t
+    LEFT JOIN projects p ON p.id = t.project_id
+    ORDER BY t.id`,
+		func(rows *sql.Rows) error {
+			var task ArchiveTask
+			err := rows.Scan(&task.ID, &task.Name, &task.Estimate, &task.Actual, &task.CreatedAt, &task.UpdatedAt, &task.Done, &task.Project, &task.DueDate, &task.Notes)
+			if err != nil {
+				return err
+			}
+			archive.Tasks = append(archive.Tasks, task)
+			return nil
+		})
+	if err != nil {
+		return Archive{}, fmt.Errorf("failed to export tasks: %v", err)
+	}
+
+	taskIndex := make(map[int]int, len(archive.Tasks))
+	for i, task := range archive.Tasks {
+		taskIndex[task.ID] = i
+		archive.Tasks[i].Tags = []string{}
+	}
+	err = queryRows(db, `SELECT tt.task_id, g.name FROM task_tags tt JOIN tags g ON g.id = tt.tag_id ORDER BY g.name`,
+		func(rows *sql.Rows) error {
+			var taskID int
+			var tag string
+			if err := rows.Scan(&taskID, &tag); err != nil {
+				return err
+			}
+			if i, ok := taskIndex[taskID]; ok {
+				archive.Tasks[i].Tags = append(archive.Tasks[i].Tags, tag)
+			}
+			return nil
+		})
+	if err != nil {
+		return Archive{}, fmt.Errorf("failed to export tags: %v", err)
+	}
+
+	err = queryRows(db, `
+    SELECT task_id, CAST(date AS TEXT), half_hour, COALESCE(task_name, ''), COALESCE(status, ''),
+        CAST(COALESCE(started_at, '') AS TEXT), CAST(COALESCE(ended_at, '') AS TEXT)
+    FROM task_tracking
+    WHERE task_id IN (SELECT id FROM tasks)
+    ORDER BY date, half_hour, task_id`,
+		func(rows *sql.Rows) error {
+			var tracking ArchiveTracking
+			if err := rows.Scan(&tracking.TaskID, &tracking.Date, &tracking.HalfHour, &tracking.TaskName, &tracking.Status,
+				&tracking.StartedAt, &tracking.EndedAt); err != nil {
+				return err
+			}
+			archive.Tracking = append(archive.Tracking, tracking)
+			return nil
+		})
+	if err != nil {
+		return Archive{}, fmt.Errorf("failed to export tracking: %v", err)
+	}
+
+	err = queryRows(db, `
+    SELECT task_id, CAST(started_at AS TEXT), CAST(COALESCE(ended_at, '') AS TEXT), planned_seconds,
+        COALESCE(outcome, ''), COALESCE(interruption, ''), COALESCE(reason, '')
+    FROM sessions
+    WHERE task_id IN (SELECT id FROM tasks)
+    ORDER BY id`,
+		func(rows *sql.Rows) error {
+			var session ArchiveSession
+			err := rows.Scan(&session.TaskID, &session.StartedAt, &session.EndedAt, &session.PlannedSeconds,
+				&session.Outcome, &session.Interruption, &session.Reason)
+			if err != nil {
+				return err
+			}
+			archive.Sessions = append(archive.Sessions, session)
+			return nil
+		})
+	if err != nil {
+		return Archive{}, fmt.Errorf("failed to export sessions: %v", err)
+	}
+
+	err = queryRows(db, `
+    SELECT kind, CAST(date AS TEXT), half_hour, CAST(started_at AS TEXT), CAST(COALESCE(ended_at, '') AS TEXT), planned_seconds
+    FROM breaks ORDER BY id`,
+		func(rows *sql.Rows) error {
+			var b ArchiveBreak
+			if err := rows.Scan(&b.Kind, &b.Date, &b.HalfHour, &b.StartedAt, &b.EndedAt, &b.PlannedSeconds); err != nil {
+				return err
+			}
+			archive.Breaks = append(archive.Breaks, b)
+			return nil
+		})
+	if err != nil {
+		return Archive{}, fmt.Errorf("failed to export breaks: %v", err)
+	}
+
+	err = queryRows(db, `SELECT key, value FROM settings ORDER BY key`, func(rows *sql.Rows) error {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		archive.Settings[key] = value
+		return nil
+	})
+	if err != nil {
+		return Archive{}, fmt.Errorf("failed to export settings: %v", err)
+	}
+
+	return archive, nil
+}
+
+// queryRows runs a query and hands every row to scan
+func queryRows(db *sql.DB, query string, scan func(rows *sql.Rows) error) error {
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// readArchive decodes an export document and checks that it can be imported
+func readArchive(r io.Reader) (Archive, error) {
+	var archive Archive
+	if err := json.NewDecoder(r).Decode(&archive); err != nil {
+		return Archive{}, fmt.Errorf("failed to read archive: %v", err)
+	}
+	if archive.Format != archiveFormat {
+		return Archive{}, fmt.Errorf("not a tomatillo export (format %q)", archive.Format)
+	}
+	if archive.Version > archiveVersion {
+		return Archive{}, fmt.Errorf("archive version %d is newer than this tomatillo supports (%d)", archive.Version, archiveVersion)
+	}
+	return archive, nil
+}
+
+// importTime converts a timestamp of an archive to the stored format. A missing
+// timestamp becomes fallback, or stays empty when fallback is the zero time.
+func importTime(value string, version int, fallback time.Time) string {
+	if value == "" {
+		if fallback.IsZero() {
+			return ""
+		}
+		return formatStoredTime(fallback)
+	}
+	naive := time.UTC
+	if version < 2 {
+		naive = time.Local
+	}
+	t, err := parseStoredTime(value, naive)
+	if err != nil {
+		return value
+	}
+	return formatStoredTime(t)
+}
+
+// importArchive merges an archive into the database in a single transaction.
+// Tasks get new IDs and every reference to them is remapped. A task with the same
+// name and creation time as an existing one is treated as the same task, so
+// importing a file twice adds nothing. Tracking rows that clash with an existing
+// row on (task_id, date, half_hour) but differ in status are conflicts: they
+// abort the import unless skipConflicts is set, in which case they are skipped.
+// Rows without a timestamp, and projects a task names but the archive does not
+// list, are stamped with now.
+func importArchive(db *sql.DB, archive Archive, skipConflicts bool, now time.Time) (ImportSummary, error) {
+	var summary ImportSummary
+
+	tx, err := db.Begin()
+	if err != nil {
+		return summary, fmt.Errorf("failed to begin import: %v", err)
+	}
+	defer tx.Rollback()
+
+	projectIDs := make(map[string]int64)
+	for _, project := range archive.Projects {
+		_, err := tx.Exec(`
+    INSERT INTO projects (name, archived, created_at) VALUES (?, ?, ?)
+    ON CONFLICT(name) DO NOTHING`, project.Name, project.Archived, importTime(project.CreatedAt, archive.Version, now))
+		if err != nil {
+			return summary, fmt.Errorf("failed to import project %s: %v", project.Name, err)
+		}
+	}
+	projectID := func(name string) (sql.NullInt64, error) {
+		if name == "" {
+			return sql.NullInt64{}, nil
+		}
+		if id, ok := projectIDs[name]; ok {
+			return sql.NullInt64{Int64: id, Valid: true}, nil
+		}
+		var id int64
+		err := tx.QueryRow(`SELECT id FROM projects WHERE name = ?`, name).Scan(&id)
+		if err == sql.ErrNoRows {
+			result, err := tx.Exec(`INSERT INTO projects (name, created_at) VALUES (?, ?)`, name, formatStoredTime(now))
+			if err != nil {
+				return sql.NullInt64{}, err
+			}
+			id, err = result.LastInsertId()
+			if err != nil {
+				return sql.NullInt64{}, err
+			}
+		} else if err != nil {
+			return sql.NullInt64{}, err
+		}
+		projectIDs[name] = id
+		return sql.NullInt64{Int64: id, Valid: true}, nil
+	}
+
+	taskIDs := make(map[int]int64, len(archive.Tasks))
+	for _, task := range archive.Tasks {
+		createdAt := importTime(task.CreatedAt, archive.Version, now)
+		var existingID int64
+		err := tx.QueryRow(`SELECT id FROM tasks WHERE name = ? AND CAST(created_at AS TEXT) = ?`, task.Name, createdAt).Scan(&existingID)
+		if err == nil {
+			taskIDs[task.ID] = existingID
+			summary.DuplicateTasks++
+			continue
+		} else if err != sql.ErrNoRows {
+			return summary, fmt.Errorf("failed to look up task %s: %v", task.Name, err)
+		}
+
+		project, err := projectID(task.Project)
+		if err != nil {
+			return summary, fmt.Errorf("failed to import project %s: %v", task.Project, err)
+		}
+
+		result, err := tx.Exec(`
+    INSERT INTO tasks (name, estimate, actual, created_at, updated_at, done, project_id, due_date, notes)
+    VALUES (?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''))`,
+			task.Name, task.Estimate, task.Actual, createdAt, importTime(task.UpdatedAt, archive.Version, now), task.Done, project, task.DueDate, task.Notes)
+		if err != nil {
+			return summary, fmt.Errorf("failed to import task %s: %v", task.Name, err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return summary, fmt.Errorf("failed to get the ID of the imported task: %v", err)
+		}
+		taskIDs[task.ID] = id
+		summary.Tasks++
+
+		for _, tag := range task.Tags {
+			tag = normalizeTag(tag)
+			if _, err := tx.Exec(`INSERT INTO tags (name) VALUES (?) ON CONFLICT(name) DO NOTHING`, tag); err != nil {
+				return summary, fmt.Errorf("failed to import tag %s: %v", tag, err)
+			}
+			_, err := tx.Exec(`
+    INSERT INTO task_tags (task_id, tag_id) SELECT ?, id FROM tags WHERE name = ?
+    ON CONFLICT(task_id, tag_id) DO NOTHING`, id, tag)
+			if err != nil {
+				return summary, fmt.Errorf("failed to tag imported task: %v", err)
+			}
+		}
+	}
+
+	for _, tracking := range archive.Tracking {
+		taskID, ok := taskIDs[tracking.TaskID]
+		if !ok {
+			return summary, fmt.Errorf("tracking row on %s refers to task %d, which is not in the archive", tracking.Date, tracking.TaskID)
+		}
+
+		var status string
+		err := tx.QueryRow(`SELECT COALESCE(status, '') FROM task_tracking WHERE task_id = ? AND date = ? AND half_hour = ?`,
+			taskID, tracking.Date, tracking.HalfHour).Scan(&status)
+		if err == nil {
+			if status == tracking.Status {
+				summary.DuplicateTracking++
+				continue
+			}
+			conflict := fmt.Sprintf("task %d on %s half hour %d is %q here and %q in the archive",
+				taskID, tracking.Date, tracking.HalfHour, status, tracking.Status)
+			summary.Conflicts = append(summary.Conflicts, conflict)
+			continue
+		} else if err != sql.ErrNoRows {
+			return summary, fmt.Errorf("failed to look up tracking row: %v", err)
+		}
+
+		_, err = tx.Exec(`
+    INSERT INTO task_tracking (task_id, date, half_hour, task_name, status, started_at, ended_at)
+    VALUES (?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''))`,
+			taskID, tracking.Date, tracking.HalfHour, tracking.TaskName, tracking.Status,
+			importTime(tracking.StartedAt, archive.Version, time.Time{}), importTime(tracking.EndedAt, archive.Version, time.Time{}))
+		if err != nil {
+			return summary, fmt.Errorf("failed to import tracking row: %v", err)
+		}
+		summary.Tracking++
+	}
+
+	if len(summary.Conflicts) > 0 && !skipConflicts {
+		return summary, fmt.Errorf("found %d conflicting tracking rows, nothing was imported", len(summary.Conflicts))
+	}
+
+	for _, session := range archive.Sessions {
+		taskID, ok := taskIDs[session.TaskID]
+		if !ok {
+			return summary, fmt.Errorf("session started at %s refers to task %d, which is not in the archive", session.StartedAt, session.TaskID)
+		}
+
+		startedAt := importTime(session.StartedAt, archive.Version, time.Time{})
+		result, err := tx.Exec(`
+    INSERT INTO sessions (task_id, started_at, ended_at, planned_seconds, outcome, interruption, reason)
+    SELECT ?, ?, NULLIF(?, ''), ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, '')
+    WHERE NOT EXISTS (SELECT 1 FROM sessions WHERE task_id = ? AND CAST(started_at AS TEXT) = ?)`,
+			taskID, startedAt, importTime(session.EndedAt, archive.Version, time.Time{}), session.PlannedSeconds, session.Outcome,
+			session.Interruption, session.Reason, taskID, startedAt)
+		if err != nil {
+			return summary, fmt.Errorf("failed to import session: %v", err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			summary.Sessions++
+		}
+	}
+
+	for _, b := range archive.Breaks {
+		startedAt := importTime(b.StartedAt, archive.Version, time.Time{})
+		result, err := tx.Exec(`
+    INSERT INTO breaks (kind, date, half_hour, started_at, ended_at, planned_seconds)
+    SELECT ?, ?, ?, ?, NULLIF(?, ''), ?
+    WHERE NOT EXISTS (SELECT 1 FROM breaks WHERE CAST(started_at AS TEXT) = ?)`,
+			b.Kind, b.Date, b.HalfHour, startedAt, importTime(b.EndedAt, archive.Version, time.Time{}), b.PlannedSeconds, startedAt)
+		if err != nil {
+			return summary, fmt.Errorf("failed to import break: %v", err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			summary.Breaks++
+		}
+	}
+
+	// settings already made on this machine win over imported ones
+	for key, value := range archive.Settings {
+		_, err := tx.Exec(`INSERT INTO settings (key, value) VALUES (?, ?) ON CONFLICT(key) DO NOTHING`, key, value)
+		if err != nil {
+			return summary, fmt.Errorf("failed to import setting %s: %v", key, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return summary, fmt.Errorf("failed to commit import: %v", err)
+	}
+	return summary, nil
+}
+
+// Helper function to handle the 'export' command
+func handleExportCommand(args []string) {
+	exportFlag := flag.NewFlagSet("export", flag.ExitOnError)
+	outPath := exportFlag.String("out", "", "File to write the export to, standard output when empty (or use -o)")
+	exportFlag.StringVar(outPath, "o", "", "File to write the export to (short version)")
+	exportFlag.Parse(args)
+
+	archive, err := exportArchive(db, clock.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out := os.Stdout
+	if *outPath != "" {
+		out, err = os.Create(*outPath)
+		if err != nil {
+			log.Fatalf("failed to create export file: %v", err)
+		}
+		defer out.Close()
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(archive); err != nil {
+		log.Fatalf("failed to write export: %v", err)
+	}
+
+	if *outPath != "" {
+		fmt.Printf("Exported %d tasks, %d tracking rows and %d sessions to %s\n",
+			len(archive.Tasks), len(archive.Tracking), len(archive.Sessions), *outPath)
+	}
+}
+
+// Helper function to handle the 'import' command
+func handleImportCommand(args []string) {
+	importFlag := flag.NewFlagSet("import", flag.ExitOnError)
+	filePath := importFlag.String("file", "", "Export file to import (or use -f)")
+	importFlag.StringVar(filePath, "f", "", "Export file to import (short version)")
+	skipConflicts := importFlag.Bool("skip-conflicts", false, "Skip conflicting tracking rows instead of aborting")
+	importFlag.Parse(args)
+
+	if *filePath == "" {
+		log.Println("Please provide the file to import with --file.")
+		os.Exit(1)
+	}
+
+	file, err := os.Open(*filePath)
+	if err != nil {
+		log.Fatalf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	archive, err := readArchive(file)
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+
+	summary, err := importArchive(db, archive, *skipConflicts, clock.Now())
+	for _, conflict := range summary.Conflicts {
+		fmt.Println("Conflict:", conflict)
+	}
+	if err != nil {
+		log.Println(err)
+		if len(summary.Conflicts) > 0 {
+			log.Println("Run again with --skip-conflicts to import everything else.")
+		}
+		os.Exit(1)
+	}
+
+	fmt.Printf("Imported %d tasks (%d already present), %d tracking rows (%d already present), %d sessions and %d breaks\n",
+		summary.Tasks, summary.DuplicateTasks, summary.Tracking, summary.DuplicateTracking, summary.Sessions, summary.Breaks)
+	if len(summary.Conflicts) > 0 {
+		fmt.Printf("Skipped %d conflicting tracking rows\n", len(summary.Conflicts))
+	}
+}
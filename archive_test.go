@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestExportImportArchive(t *testing.T) {
+	source := initializeDatabase(":memory:")
+	defer source.Close()
+
+	db = source
+	if _, err := addProject(source, "Acme", time.Now()); err != nil {
+		t.Fatalf("failed to add project: %v", err)
+	}
+	if _, err := createTask(source, Task{Name: "Fix login", Estimate: 2, Project: "Acme", Tags: []string{"bug"}}, time.Now()); err != nil {
+		t.Fatalf("failed to add task: %v", err)
+	}
+	if err := insertTrackingTask(1, "2024-09-21", 20); err != nil {
+		t.Fatalf("failed to track task: %v", err)
+	}
+	if _, err := startSession(source, 1, 25*time.Minute, time.Date(2024, time.September, 21, 10, 0, 0, 0, time.Local)); err != nil {
+		t.Fatalf("failed to start session: %v", err)
+	}
+
+	archive, err := exportArchive(source, time.Now())
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	// round trip through JSON like a real backup file
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(archive); err != nil {
+		t.Fatalf("failed to encode archive: %v", err)
+	}
+	archive, err = readArchive(&buf)
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+
+	target := initializeDatabase(":memory:")
+	defer target.Close()
+	db = target
+
+	// an existing task takes ID 1, so the imported task must be remapped
+	if _, err := createTask(target, Task{Name: "Local task", Estimate: 1}, time.Now()); err != nil {
+		t.Fatalf("failed to add task: %v", err)
+	}
+
+	summary, err := importArchive(target, archive, false, time.Now())
+	if err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	if summary.Tasks != 1 || summary.Tracking != 1 || summary.Sessions != 1 {
+		t.Errorf("unexpected import summary: %+v", summary)
+	}
+
+	var taskID int
+	var project string
+	err = target.QueryRow(`
+    SELECT t.id, p.name FROM tasks t JOIN projects p ON p.id = t.project_id WHERE t.name = 'Fix login'`).Scan(&taskID, &project)
+	if err != nil {
+		t.Fatalf("failed to find imported task: %v", err)
+	}
+	if taskID != 2 || project != "Acme" {
+		t.Errorf("expected the imported task to get ID 2 in Acme, got %d in %q", taskID, project)
+	}
+
+	tracking, err := getTasksForDay("2024-09-21")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tracking) != 1 || tracking[0].TaskID != 2 {
+		t.Errorf("expected the tracking row to point at task 2, got %+v", tracking)
+	}
+
+	// importing the same archive again adds nothing
+	summary, err = importArchive(target, archive, false, time.Now())
+	if err != nil {
+		t.Fatalf("failed to import again: %v", err)
+	}
+	if summary.Tasks != 0 || summary.DuplicateTasks != 1 || summary.Tracking != 0 || summary.Sessions != 0 {
+		t.Errorf("expected a second import to add nothing, got %+v", summary)
+	}
+}
+
+func TestImportArchiveConflicts(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	if _, err := createTask(db, Task{Name: "Fix login", Estimate: 2}, time.Now()); err != nil {
+		t.Fatalf("failed to add task: %v", err)
+	}
+	if err := insertTrackingTask(1, "2024-09-21", 20); err != nil {
+		t.Fatalf("failed to track task: %v", err)
+	}
+
+	archive, err := exportArchive(db, time.Now())
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	archive.Tracking[0].Status = "done"
+	archive.Tasks = append(archive.Tasks, ArchiveTask{ID: 7, Name: "New task", Estimate: 1, CreatedAt: "2024-09-21 09:00:00"})
+
+	if _, err := importArchive(db, archive, false, time.Now()); err == nil {
+		t.Fatal("expected the conflicting tracking row to abort the import")
+	}
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM tasks`).Scan(&count); err != nil {
+		t.Fatalf("failed to count tasks: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected the aborted import to add nothing, got %d tasks", count)
+	}
+
+	summary, err := importArchive(db, archive, true, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(summary.Conflicts) != 1 || summary.Tasks != 1 {
+		t.Errorf("expected 1 skipped conflict and 1 new task, got %+v", summary)
+	}
+}
+
+func TestImportVersion1Archive(t *testing.T) {
+	defer pinTimezone(t, "Europe/Paris")()
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	// version 1 wrote task times in local time without an offset
+	archive := Archive{
+		Format:  archiveFormat,
+		Version: 1,
+		Tasks:   []ArchiveTask{{ID: 1, Name: "Fix login", Estimate: 1, CreatedAt: "2024-09-21 09:00:00", UpdatedAt: "2024-09-21 10:00:00"}},
+		Sessions: []ArchiveSession{{TaskID: 1, StartedAt: "2024-09-21 09:30:00+02:00", EndedAt: "2024-09-21 09:55:00+02:00",
+			PlannedSeconds: 1500, Outcome: "completed"}},
+	}
+	if _, err := importArchive(db, archive, false, time.Now()); err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+
+	var createdAt, startedAt string
+	err := db.QueryRow(`
+    SELECT CAST(t.created_at AS TEXT), CAST(s.started_at AS TEXT) FROM tasks t JOIN sessions s ON s.task_id = t.id`).Scan(&createdAt, &startedAt)
+	if err != nil {
+		t.Fatalf("failed to read imported timestamps: %v", err)
+	}
+	if createdAt != "2024-09-21 07:00:00" || startedAt != "2024-09-21 07:30:00" {
+		t.Errorf("expected the timestamps in UTC, got %s and %s", createdAt, startedAt)
+	}
+
+	// the same archive again is recognized as the same task
+	summary, err := importArchive(db, archive, false, time.Now())
+	if err != nil || summary.DuplicateTasks != 1 || summary.Sessions != 0 {
+		t.Errorf("expected a second import to add nothing, got %+v (%v)", summary, err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Clock tells the current time. Commands ask clock instead of calling time.Now
+// and hand the time down to the database and reports, so --now can replay
+// another day and tests can pass any time they like.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the wall clock
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// fixedClock always tells the same time
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+// shiftedClock runs like the wall clock from another starting point, so a timer
+// started under --now still counts down
+type shiftedClock time.Duration
+
+func (c shiftedClock) Now() time.Time { return time.Now().Add(time.Duration(c)) }
+
+var clock Clock = systemClock{}
+
+// parseNow reads the value of --now in local time: a date, which stands for the
+// end of that day so reports show all of it, a date and time, or RFC 3339
+func parseNow(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02 15:04:05", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --now %q, expected YYYY-MM-DD, YYYY-MM-DD HH:MM or RFC 3339", value)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNow(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2026-03-01", time.Date(2026, time.March, 1, 23, 59, 59, 0, time.Local)},
+		{"2026-03-01 09:30", time.Date(2026, time.March, 1, 9, 30, 0, 0, time.Local)},
+		{"2026-03-01T09:30", time.Date(2026, time.March, 1, 9, 30, 0, 0, time.Local)},
+		{"2026-03-01T09:30:00Z", time.Date(2026, time.March, 1, 9, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseNow(tt.value)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseNow(%q) = %v (%v), expected %v", tt.value, got, err, tt.want)
+		}
+	}
+	if _, err := parseNow("yesterday"); err == nil {
+		t.Error("expected an error for a value that is not a date")
+	}
+}
+
+func TestNowOnlyReads(t *testing.T) {
+	for _, command := range []string{"report", "today", "list", "export"} {
+		if _, _, err := parseGlobalFlags([]string{"--now", "2026-03-01", command}); err != nil {
+			t.Errorf("expected --now with %s to be allowed, got %v", command, err)
+		}
+	}
+	for _, command := range []string{"add", "start", "done", "load", "import", "config", "serve"} {
+		if _, _, err := parseGlobalFlags([]string{"--now", "2026-03-01", command}); err == nil {
+			t.Errorf("expected --now with %s to be rejected", command)
+		}
+	}
+}
+
+func TestShiftedClock(t *testing.T) {
+	then := time.Date(2026, time.March, 1, 9, 30, 0, 0, time.Local)
+	c := shiftedClock(time.Until(then))
+	if got := c.Now(); got.Before(then) || got.Sub(then) > time.Minute {
+		t.Errorf("expected the clock to start at %v, got %v", then, got)
+	}
+}
+
+func TestReplayedDay(t *testing.T) {
+	defer pinTimezone(t, "Europe/Paris")()
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	// a task added late in the evening of 2026-03-01, replayed the next day
+	now := time.Date(2026, time.March, 1, 23, 30, 0, 0, time.Local)
+	id, err := createTask(db, Task{Name: "Write report", Estimate: 2}, now)
+	if err != nil {
+		t.Fatalf("failed to add task: %v", err)
+	}
+	if _, err := beginPomodoro(id, defaultPomodoroDuration, now); err != nil {
+		t.Fatalf("failed to begin pomodoro: %v", err)
+	}
+
+	task, err := getTask(db, id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !task.CreatedAt.Equal(now) {
+		t.Errorf("expected the task to be created at %v, got %v", now, task.CreatedAt)
+	}
+	tracking, err := getTasksForDay("2026-03-01")
+	if err != nil || len(tracking) != 1 || tracking[0].HalfHour != 47 {
+		t.Errorf("expected the pomodoro in the last half hour of 2026-03-01, got %+v (%v)", tracking, err)
+	}
+
+	tasks, err := getDailyTasks(db, now)
+	if err != nil || len(tasks) != 1 {
+		t.Errorf("expected the task among today's tasks, got %d (%v)", len(tasks), err)
+	}
+
+	nextDay := time.Date(2026, time.March, 2, 0, 30, 0, 0, time.Local)
+	if tasks, err := getTodayTasks(reportOptions{now: nextDay}); err != nil || len(tasks) != 0 {
+		t.Errorf("expected no tasks the next day, got %d (%v)", len(tasks), err)
+	}
+	if tasks, err := getTasks(1, "all", nextDay); err != nil || len(tasks) != 1 {
+		t.Errorf("expected the task among those of the last day, got %d (%v)", len(tasks), err)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// settingDefaults lists every setting that can be stored with 'config set'
+// together with the value used when it has not been set.
+var settingDefaults = map[string]string{
+	"cycle":       "4",
+	"short_break": "5m",
+	"long_break":  "15m",
+	"week_start":  "sunday",
+	"timezone":    "Local",
+}
+
+// settingValidators check a value before it is stored
+var settingValidators = map[string]func(string) error{
+	"cycle":       validatePositiveInt,
+	"short_break": validatePositiveDuration,
+	"long_break":  validatePositiveDuration,
+	"week_start":  validateWeekday,
+	"timezone":    validateTimezone,
+}
+
+func validatePositiveInt(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("expected a positive number, got %q", value)
+	}
+	return nil
+}
+
+func validatePositiveDuration(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("expected a positive duration such as 5m, got %q", value)
+	}
+	return nil
+}
+
+func validateWeekday(value string) error {
+	if _, err := parseWeekday(value); err != nil {
+		return err
+	}
+	return nil
+}
+
+// parseWeekday reads the English name of a day of the week, in any case
+func parseWeekday(value string) (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(value, day.String()) {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("expected a day of the week such as monday, got %q", value)
+}
+
+// getConfig returns the current value of a known setting
+func getConfig(db *sql.DB, key string) (string, error) {
+	fallback, ok := settingDefaults[key]
+	if !ok {
+		return "", fmt.Errorf("unknown setting %q", key)
+	}
+	return getSetting(db, key, fallback)
+}
+
+func getIntConfig(db *sql.DB, key string) (int, error) {
+	value, err := getConfig(db, key)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+func getDurationConfig(db *sql.DB, key string) (time.Duration, error) {
+	value, err := getConfig(db, key)
+	if err != nil {
+		return 0, err
+	}
+	return time.ParseDuration(value)
+}
+
+// loadWeekStart reads the week_start setting into weekStart, which decides how
+// getWeek and every weekly report split the calendar
+func loadWeekStart(db *sql.DB) error {
+	value, err := getConfig(db, "week_start")
+	if err != nil {
+		return err
+	}
+	weekStart, err = parseWeekday(value)
+	return err
+}
+
+// setConfig validates and stores a known setting
+func setConfig(db *sql.DB, key, value string) error {
+	if _, ok := settingDefaults[key]; !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	if err := settingValidators[key](value); err != nil {
+		return fmt.Errorf("invalid value for %s: %v", key, err)
+	}
+	return setSetting(db, key, value)
+}
+
+// Helper function to handle the 'config' command
+func handleConfigCommand(args []string) {
+	configFlag := flag.NewFlagSet("config", flag.ExitOnError)
+	configFlag.Usage = func() {
+		fmt.Println("Usage: tomatillo config [list | get <key> | set <key> <value>]")
+	}
+	configFlag.Parse(args)
+	rest := configFlag.Args()
+
+	if len(rest) == 0 || rest[0] == "list" {
+		keys := make([]string, 0, len(settingDefaults))
+		for key := range settingDefaults {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value, err := getConfig(db, key)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("%s = %s\n", key, value)
+		}
+		return
+	}
+
+	var err error
+	switch {
+	case rest[0] == "get" && len(rest) == 2:
+		var value string
+		value, err = getConfig(db, rest[1])
+		if err == nil {
+			fmt.Println(value)
+		}
+	case rest[0] == "set" && len(rest) == 3:
+		err = setConfig(db, rest[1], rest[2])
+		if err == nil {
+			fmt.Printf("%s = %s\n", rest[1], rest[2])
+		}
+	default:
+		configFlag.Usage()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
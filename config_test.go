@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfig(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	cycle, err := getIntConfig(db, "cycle")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cycle != 4 {
+		t.Errorf("expected the default cycle to be 4, got %d", cycle)
+	}
+
+	tests := []struct {
+		key         string
+		value       string
+		expectError bool
+	}{
+		{"cycle", "3", false},
+		{"cycle", "0", true},
+		{"long_break", "20m", false},
+		{"short_break", "soon", true},
+		{"week_start", "Monday", false},
+		{"week_start", "mon", true},
+		{"timezone", "Europe/Paris", false},
+		{"timezone", "Mars/Olympus_Mons", true},
+		{"unknown", "1", true},
+	}
+
+	for _, tt := range tests {
+		err := setConfig(db, tt.key, tt.value)
+		if tt.expectError && err == nil {
+			t.Errorf("setConfig(%q, %q) expected an error, got none", tt.key, tt.value)
+		}
+		if !tt.expectError && err != nil {
+			t.Errorf("setConfig(%q, %q) returned an error: %v", tt.key, tt.value, err)
+		}
+	}
+
+	long, err := getDurationConfig(db, "long_break")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if long != 20*time.Minute {
+		t.Errorf("expected long_break to be 20m, got %v", long)
+	}
+}
+
+func TestLoadWeekStart(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+	defer func() { weekStart = time.Sunday }()
+
+	if err := loadWeekStart(db); err != nil || weekStart != time.Sunday {
+		t.Fatalf("expected weeks to start on Sunday by default, got %v (%v)", weekStart, err)
+	}
+	if err := setConfig(db, "week_start", "monday"); err != nil {
+		t.Fatalf("failed to set week_start: %v", err)
+	}
+	if err := loadWeekStart(db); err != nil || weekStart != time.Monday {
+		t.Fatalf("expected weeks to start on Monday, got %v (%v)", weekStart, err)
+	}
+
+	first, last := getWeek(time.Date(2026, time.January, 1, 12, 0, 0, 0, time.Local))
+	if formatDate(first) != "2025-12-29" || formatDate(last) != "2026-01-04" {
+		t.Errorf("expected the Monday to Sunday week, got %s to %s", formatDate(first), formatDate(last))
+	}
+}
+
+func TestLoadTimezone(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+
+	if err := setConfig(db, "timezone", "Asia/Tokyo"); err != nil {
+		t.Fatalf("failed to set timezone: %v", err)
+	}
+	if err := loadTimezone(db); err != nil || time.Local.String() != "Asia/Tokyo" {
+		t.Fatalf("expected times in Asia/Tokyo, got %v (%v)", time.Local, err)
+	}
+
+	// 20:00 UTC is already the next day in Tokyo
+	if _, err := startBreak(db, "short", 5*time.Minute, time.Date(2026, time.March, 8, 20, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("failed to start break: %v", err)
+	}
+	b, err := getRunningBreak(db)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := b.StartedAt.Local().Format("15:04"); !strings.HasPrefix(b.Date, "2026-03-09") || b.HalfHour != 10 || got != "05:00" {
+		t.Errorf("expected the break at 05:00 on 2026-03-09 in Tokyo, got %s half hour %d at %s", b.Date, b.HalfHour, got)
+	}
+}
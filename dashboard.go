@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// The dashboard is a single HTML page with its stylesheet, script and data
+// inlined, so the file it writes can be opened offline or sent to someone.
+//
+//go:embed dashboard
+var dashboardAssets embed.FS
+
+var dashboardTemplate = template.Must(template.ParseFS(dashboardAssets, "dashboard/dashboard.html"))
+
+// DashboardData is everything the dashboard page draws
+type DashboardData struct {
+	GeneratedAt time.Time               `json:"generated_at"`
+	Year        int                     `json:"year"`
+	Yearly      []TaskTrackingAggregate `json:"yearly"`
+	WeekStart   string                  `json:"week_start"`
+	WeekNumber  string                  `json:"week_number"`
+	FirstDay    time.Weekday            `json:"first_day"` // day the weeks start on, 0 for Sunday
+	Week        []TaskTracking          `json:"week"`
+	TaskNames   map[int]string          `json:"task_names"`
+	Days        int                     `json:"days"`
+	Tasks       []Task                  `json:"tasks"`
+}
+
+// getDashboardData collects the yearly counts for year, the half hours tracked
+// in the week of now and the tasks created in the last days
+func getDashboardData(db *sql.DB, now time.Time, year, days int) (DashboardData, error) {
+	data := DashboardData{GeneratedAt: now, Year: year, Days: days, TaskNames: map[int]string{}}
+
+	var err error
+	if data.Yearly, err = getYearlyData(db, year); err != nil {
+		return data, fmt.Errorf("failed to get yearly data: %w", err)
+	}
+
+	start, end := getWeek(now)
+	data.WeekStart = start.Format("2006-01-02")
+	data.WeekNumber = isoWeek(start)
+	data.FirstDay = weekStart
+	if data.Week, err = getTrackingForRange(start, end, ""); err != nil {
+		return data, fmt.Errorf("failed to get tracking records: %w", err)
+	}
+	for _, tracking := range data.Week {
+		if _, ok := data.TaskNames[tracking.TaskID]; ok {
+			continue
+		}
+		task, err := getTask(db, tracking.TaskID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return data, fmt.Errorf("failed to get task %d: %w", tracking.TaskID, err)
+		}
+		data.TaskNames[task.ID] = task.Name
+	}
+
+	if data.Tasks, err = getFilteredTasks(TaskFilter{Days: days, Now: now, Status: "all"}); err != nil {
+		return data, fmt.Errorf("failed to get tasks: %w", err)
+	}
+	if data.Tasks == nil {
+		data.Tasks = []Task{}
+	}
+	return data, nil
+}
+
+// writeDashboard renders the dashboard page with its assets and data inlined
+func writeDashboard(w io.Writer, data DashboardData) error {
+	style, err := dashboardAssets.ReadFile("dashboard/dashboard.css")
+	if err != nil {
+		return err
+	}
+	script, err := dashboardAssets.ReadFile("dashboard/dashboard.js")
+	if err != nil {
+		return err
+	}
+	// json.Marshal escapes <, > and &, so the data cannot close the script tag
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return dashboardTemplate.Execute(w, struct {
+		Year   int
+		Style  template.CSS
+		Script template.JS
+		Data   template.JS
+	}{data.Year, template.CSS(style), template.JS(script), template.JS(encoded)})
+}
+
+// newDashboardHandler serves the page at /. It is rendered again on every request
+// so reloading shows new pomodoros, and only to this machine, like the API.
+func newDashboardHandler(render func(io.Writer) error) http.Handler {
+	return guardLocalRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		var page bytes.Buffer
+		if err := render(&page); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		page.WriteTo(w)
+	}))
+}
+
+func handleDashboardCommand(args []string) {
+	dashboardFlag := flag.NewFlagSet("dashboard", flag.ExitOnError)
+	out := dashboardFlag.String("out", "tomatillo-dashboard.html", "File to write the dashboard to")
+	serve := dashboardFlag.String("serve", "", "Serve the dashboard on this address instead of writing a file")
+	year := dashboardFlag.Int("year", clock.Now().Year(), "Year of the heatmap")
+	days := dashboardFlag.Int("days", 30, "Number of days of tasks to chart")
+	dashboardFlag.Parse(args)
+
+	render := func(w io.Writer) error {
+		data, err := getDashboardData(db, clock.Now(), *year, *days)
+		if err != nil {
+			return err
+		}
+		return writeDashboard(w, data)
+	}
+
+	if *serve != "" {
+		server := &http.Server{Addr: *serve, Handler: newDashboardHandler(render), ReadHeaderTimeout: 10 * time.Second}
+		log.Printf("Serving the tomatillo dashboard on http://%s/", *serve)
+		if err := server.ListenAndServe(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var page bytes.Buffer
+	if err := render(&page); err != nil {
+		log.Fatalf("Failed to render the dashboard: %v", err)
+	}
+	if err := os.WriteFile(*out, page.Bytes(), 0644); err != nil {
+		log.Fatalf("Failed to write the dashboard: %v", err)
+	}
+	fmt.Printf("Wrote the dashboard to %s\n", *out)
+}
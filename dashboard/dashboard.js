@@ -0,0 +1,120 @@
+// Renders the data that 'tomatillo dashboard' embedded in the page. Everything is
+// drawn with plain DOM elements so the page works offline.
+(function () {
+  "use strict";
+
+  var data = JSON.parse(document.getElementById("dashboard-data").textContent);
+
+  function element(tag, className, text) {
+    var el = document.createElement(tag);
+    if (className) {
+      el.className = className;
+    }
+    if (text !== undefined) {
+      el.textContent = text;
+    }
+    return el;
+  }
+
+  function pad(n) {
+    return (n < 10 ? "0" : "") + n;
+  }
+
+  function isoDate(year, month, day) {
+    return year + "-" + pad(month) + "-" + pad(day);
+  }
+
+  document.getElementById("generated").textContent = "Generated " + new Date(data.generated_at).toLocaleString();
+
+  // yearly heatmap: a column per week, a row per day of the week starting on first_day
+  (function () {
+    var heatmap = document.getElementById("heatmap");
+    var days = data.yearly || [];
+    var max = 0;
+    days.forEach(function (d) { max = Math.max(max, d.task_count); });
+
+    if (days.length > 0) {
+      var first = new Date(days[0].year, days[0].month - 1, days[0].day);
+      for (var i = 0; i < (first.getDay() - data.first_day + 7) % 7; i++) {
+        heatmap.appendChild(element("div", "empty"));
+      }
+    }
+
+    days.forEach(function (d) {
+      var level = 0;
+      if (d.task_count > 0) {
+        level = Math.min(4, Math.ceil(4 * d.task_count / max));
+      }
+      var cell = element("div", "level-" + level);
+      cell.title = isoDate(d.year, d.month, d.day) + ": " + d.task_count + " pomodoros";
+      heatmap.appendChild(cell);
+    });
+  })();
+
+  // weekly half-hour grid, like the blockweek report
+  (function () {
+    var week = document.getElementById("week");
+    var names = data.task_names || {};
+    var slots = {};
+    (data.week || []).forEach(function (t) {
+      var key = t.date + "/" + t.half_hour;
+      slots[key] = slots[key] || [];
+      slots[key].push(names[t.task_id] || "Task " + t.task_id);
+    });
+
+    document.getElementById("week-title").textContent = data.week_number + ", week of " + data.week_start;
+    week.appendChild(element("div"));
+    for (var hour = 0; hour < 24; hour++) {
+      week.appendChild(element("div", "hour", pad(hour)));
+    }
+
+    var start = new Date(data.week_start + "T00:00:00");
+    for (var day = 0; day < 7; day++) {
+      var date = new Date(start.getFullYear(), start.getMonth(), start.getDate() + day);
+      var key = isoDate(date.getFullYear(), date.getMonth() + 1, date.getDate());
+      week.appendChild(element("div", "day", date.toLocaleDateString(undefined, { weekday: "short" }) + " " + key.slice(5)));
+
+      for (var halfHour = 0; halfHour < 48; halfHour++) {
+        var tasks = slots[key + "/" + halfHour];
+        var slot = element("div", tasks ? "slot work" : "slot");
+        slot.title = pad(Math.floor(halfHour / 2)) + ":" + (halfHour % 2 ? "30" : "00") + (tasks ? " " + tasks.join(", ") : "");
+        week.appendChild(slot);
+      }
+    }
+  })();
+
+  // estimate vs actual per task
+  (function () {
+    var container = document.getElementById("tasks");
+    var tasks = (data.tasks || []).filter(function (t) { return t.estimate > 0 || t.actual > 0; });
+    document.getElementById("tasks-title").textContent = "Estimate vs actual, last " + data.days + " days";
+
+    if (tasks.length === 0) {
+      container.appendChild(element("p", "empty-state", "No tasks yet."));
+      return;
+    }
+
+    var max = 1;
+    tasks.forEach(function (t) { max = Math.max(max, t.estimate, t.actual); });
+
+    tasks.forEach(function (t) {
+      var name = element("div", "name", t.name);
+      name.title = t.name + (t.project ? " · " + t.project : "");
+      container.appendChild(name);
+
+      var bars = element("div", "bars");
+      var estimate = element("div", "estimate");
+      estimate.style.width = (100 * t.estimate / max) + "%";
+      estimate.title = "Estimate: " + t.estimate;
+      var actual = element("div", "actual");
+      actual.style.width = (100 * t.actual / max) + "%";
+      actual.title = "Actual: " + t.actual;
+      bars.appendChild(estimate);
+      bars.appendChild(actual);
+      container.appendChild(bars);
+
+      var ratio = t.estimate > 0 ? t.actual + " / " + t.estimate : t.actual + " / –";
+      container.appendChild(element("div", t.actual > t.estimate ? "ratio over" : "ratio", ratio));
+    });
+  })();
+})();
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboard(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	if _, err := createTask(db, Task{Name: "Fix </script> login", Estimate: 2}, time.Now()); err != nil {
+		t.Fatalf("failed to add task: %v", err)
+	}
+	if err := insertTrackingTask(1, "2024-09-18", 20); err != nil {
+		t.Fatalf("failed to track task: %v", err)
+	}
+
+	now := time.Date(2024, 9, 19, 10, 0, 0, 0, time.Local)
+	data, err := getDashboardData(db, now, 2024, 30000)
+	if err != nil {
+		t.Fatalf("failed to get dashboard data: %v", err)
+	}
+	if len(data.Yearly) != 366 || data.WeekStart != "2024-09-15" || len(data.Week) != 1 || data.TaskNames[1] != "Fix </script> login" || len(data.Tasks) != 1 {
+		t.Fatalf("unexpected dashboard data: %+v", data)
+	}
+
+	var page bytes.Buffer
+	if err := writeDashboard(&page, data); err != nil {
+		t.Fatalf("failed to write dashboard: %v", err)
+	}
+	html := page.String()
+
+	if strings.Contains(html, "Fix </script> login") {
+		t.Errorf("expected the task name to be escaped in the page")
+	}
+	if regexp.MustCompile(`(src|href)=|https?://`).MatchString(html) {
+		t.Errorf("expected the page not to load anything from outside")
+	}
+	if !strings.Contains(html, ".heatmap") || !strings.Contains(html, "JSON.parse") {
+		t.Errorf("expected the stylesheet and script to be inlined")
+	}
+
+	// the embedded data decodes back to what was collected
+	match := regexp.MustCompile(`(?s)<script id="dashboard-data" type="application/json">(.*?)</script>`).FindStringSubmatch(html)
+	if match == nil {
+		t.Fatalf("expected the data in the page")
+	}
+	var embedded DashboardData
+	if err := json.Unmarshal([]byte(match[1]), &embedded); err != nil {
+		t.Fatalf("failed to decode the embedded data: %v", err)
+	}
+	if embedded.Year != 2024 || len(embedded.Week) != 1 || embedded.TaskNames[1] != "Fix </script> login" {
+		t.Errorf("unexpected embedded data: %+v", embedded)
+	}
+}
+
+func TestDashboardRejectsOtherSites(t *testing.T) {
+	handler := newDashboardHandler(func(w io.Writer) error {
+		_, err := io.WriteString(w, "<html></html>")
+		return err
+	})
+
+	tests := []struct {
+		name   string
+		host   string
+		origin string
+		want   int
+	}{
+		{"loopback", "127.0.0.1:7879", "", http.StatusOK},
+		{"same origin", "localhost:7879", "http://localhost:7879", http.StatusOK},
+		{"rebound host", "attacker.example:7879", "", http.StatusForbidden},
+		{"cross origin", "127.0.0.1:7879", "https://attacker.example", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		request := httptest.NewRequest("GET", "/", nil)
+		request.Host = tt.host
+		if tt.origin != "" {
+			request.Header.Set("Origin", tt.origin)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, recorder.Code)
+		}
+		if tt.want == http.StatusOK && recorder.Body.String() != "<html></html>" {
+			t.Errorf("%s: expected the page, got %q", tt.name, recorder.Body.String())
+		}
+	}
+}
@@ -2,13 +2,22 @@ package main
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// execer is satisfied by both *sql.DB and *sql.Tx, so helpers that add rows can
+// also run inside a transaction
+type execer interface {
+    Exec(query string, args ...interface{}) (sql.Result, error)
+    QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 type DayAggregate struct {
     Day             string
     TotalEstimate   int
@@ -17,106 +26,174 @@ type DayAggregate struct {
 }
 
 type TaskTrackingAggregate struct {
-    Year        int
-    Month       time.Month
-    Day         int
-    TaskCount   int
+    Year        int         `json:"year"`
+    Month       time.Month  `json:"month"`
+    Day         int         `json:"day"`
+    TaskCount   int         `json:"task_count"`
 }
 
 
+// TaskFilter narrows down the tasks returned by getFilteredTasks
+type TaskFilter struct {
+    ID      int // a single task, Days is ignored when set
+    Days    int // tasks created since the start of the day Days days before Now
+    Now     time.Time // the time Days counts back from
+    Status  string // "all", "done", "todo" or "wip"
+    Project string // project name, empty for every project
+    Tag     string // tag name, empty for every tag
+}
+
+// ProjectAggregate sums up the estimates and actuals of a project's tasks
+type ProjectAggregate struct {
+    Project   string  `json:"project"`
+    Tasks     int     `json:"tasks"`
+    Estimate  int     `json:"estimate"`
+    Actual    int     `json:"actual"`
+    Done      int     `json:"done"`
+}
+
+// Orphan is a row whose foreign key points at a row that no longer exists
+type Orphan struct {
+    Table   string
+    RowID   int64
+    Parent  string
+}
+
 type TaskTracking struct {
-    TaskID      int
-    Date        string
-    HalfHour    int
-    Status      string // e.g., "in progress", "done", etc.
+    TaskID      int     `json:"task_id"`
+    Date        string  `json:"date"`
+    HalfHour    int     `json:"half_hour"`
+    Status      string  `json:"status"` // e.g., "in progress", "done", etc.
+    StartedAt   *time.Time `json:"started_at,omitempty"` // nil for rows tracked before timestamps were recorded
+    EndedAt     *time.Time `json:"ended_at,omitempty"`   // nil while the pomodoro is running
+    Planned     time.Duration `json:"-"` // length of the pomodoro's session, zero when it has none
+}
+
+// Break is a short or long rest between pomodoros
+type Break struct {
+    ID        int
+    Kind      string // "short" or "long"
+    Date      string
+    HalfHour  int
+    StartedAt time.Time
+    EndedAt   sql.NullTime
+    Planned   time.Duration
 }
 
 // Struct to hold task data
 type Task struct {
-    ID        int
-    Name      string
-    Estimate  int
-    Actual    int
-    CreatedAt time.Time
-    UpdatedAt time.Time
-    Done      bool
-    Status    string
+    ID        int        `json:"id"`
+    Name      string     `json:"name"`
+    Estimate  int        `json:"estimate"`
+    Actual    int        `json:"actual"`
+    CreatedAt time.Time  `json:"created_at"`
+    UpdatedAt time.Time  `json:"updated_at"`
+    Done      bool       `json:"done"`
+    Status    string     `json:"status"`
+    Project   string     `json:"project"`
+    Tags      []string   `json:"tags"`
+    InternalInterruptions int  `json:"internal_interruptions"`
+    ExternalInterruptions int  `json:"external_interruptions"`
+    DueDate   string     `json:"due_date"`
+    Notes     string     `json:"notes"`
+}
+
+// Session is a single pomodoro attempt on a task. EndedAt and Outcome stay
+// empty while the pomodoro is still running.
+type Session struct {
+    ID           int
+    TaskID       int
+    StartedAt    time.Time
+    EndedAt      sql.NullTime
+    Planned      time.Duration
+    Outcome      string // "completed", "interrupted" or "abandoned"
+    Interruption string // "internal" or "external" for interrupted sessions
+    Reason       string
+    TaskName     string
+}
+
+// Deadline is when the pomodoro is due to complete
+func (s Session) Deadline() time.Time {
+    return s.StartedAt.Add(s.Planned)
 }
 
 func initializeDatabase(dbPath string) *sql.DB {
-    db, err := sql.Open("sqlite3", dbPath)
-    if err != nil {
-        log.Fatal(err)
-    }
+    db := openDatabase(dbPath)
 
-    // Create tasks table if it doesn't exist
-    createTableQuery := `
-    CREATE TABLE IF NOT EXISTS tasks (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        name TEXT NOT NULL,
-        estimate INTEGER NOT NULL,
-        actual INTEGER DEFAULT 0,
-        created_at DATETIME DEFAULT (datetime('now', 'localtime')),
-        updated_at DATETIME DEFAULT (datetime('now', 'localtime')),
-        done BOOLEAN DEFAULT 0
-    );`
-    _, err = db.Exec(createTableQuery)
-    if err != nil {
+    if _, err := migrateDatabase(db, false); err != nil {
         log.Fatal(err)
     }
 
-    createTasksTableQuery := `
-    CREATE TABLE IF NOT EXISTS task_tracking (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        task_id INTEGER NOT NULL,
-        date DATE NOT NULL,
-        half_hour INTEGER NOT NULL CHECK (half_hour BETWEEN 0 AND 47),
-        task_name TEXT,
-        status TEXT DEFAULT 'active',
-        FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
-        UNIQUE(task_id, date, half_hour)
-    );`
+    return db
+}
 
-    _, err = db.Exec(createTasksTableQuery)
+// openDatabase opens the database without bringing its schema up to date.
+// Foreign keys are switched on for every connection so deleting a task
+// cascades to its tracking rows and sessions.
+func openDatabase(dbPath string) *sql.DB {
+    separator := "?"
+    if strings.Contains(dbPath, "?") {
+        separator = "&"
+    }
+
+    db, err := sql.Open("sqlite3", dbPath+separator+"_foreign_keys=on")
     if err != nil {
         log.Fatal(err)
     }
-
     return db
 }
 
-func getDailyTasks(db *sql.DB) ([]Task, error){
+func getDailyTasks(db *sql.DB, now time.Time) ([]Task, error){
+    today := formatDate(now.Local())
+    return getTasksCreatedBetween(db, today, today)
+}
+
+// getTasksCreatedBetween returns the tasks created from one day to another
+// (inclusive, formatted 2006-01-02), oldest first
+func getTasksCreatedBetween(db *sql.DB, from, to string) ([]Task, error) {
 	query := `
-    SELECT id, name, estimate, actual, created_at, updated_at, done 
-    FROM tasks 
-    WHERE DATE(datetime(created_at, 'localtime')) = DATE('now', 'localtime')
-    ORDER BY created_at;
+    SELECT t.id, t.name, t.estimate, t.actual, t.created_at, t.updated_at, t.done, COALESCE(p.name, ''),
+        COALESCE(CAST(t.due_date AS TEXT), ''), COALESCE(t.notes, '')
+    FROM tasks t
+    LEFT JOIN projects p ON p.id = t.project_id
+    WHERE t.created_at >= ? AND t.created_at < ?
+    ORDER BY t.created_at;
     `
 
-	rows, err := db.Query(query)
+	start, end, err := storedDayRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(query, start, end)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("failed to get tasks: %v", err)
 	}
 	defer rows.Close()
 
     var tasks []Task
     for rows.Next() {
         var id, estimate, actual int
-        var name string
+        var name, project, dueDate, notes string
         var createdAt, updatedAt time.Time
         var done bool
 
-        err := rows.Scan(&id, &name, &estimate, &actual, &createdAt, &updatedAt, &done)
+        err := rows.Scan(&id, &name, &estimate, &actual, &createdAt, &updatedAt, &done, &project, &dueDate, &notes)
         if err != nil {
-            log.Fatal(err)
+            return nil, err
         }
 
-        tasks = append(tasks, Task{ ID: id, Name: name, Estimate: estimate, Actual: actual, CreatedAt: createdAt, UpdatedAt: updatedAt, Done: done })
+        tasks = append(tasks, Task{ ID: id, Name: name, Estimate: estimate, Actual: actual, CreatedAt: createdAt.Local(), UpdatedAt: updatedAt.Local(), Done: done, Status: taskStatus(actual, done), Project: project, DueDate: dueDate, Notes: notes })
     }
-    return tasks, nil
+    return tasks, rows.Err()
 }
 
 func getYearlyData(db *sql.DB, year int) ([]TaskTrackingAggregate, error) {
+    return getProjectYearlyData(db, year, "")
+}
+
+// getProjectYearlyData counts the half hours tracked on each day of the year,
+// limited to the tasks of a project unless project is empty
+func getProjectYearlyData(db *sql.DB, year int, project string) ([]TaskTrackingAggregate, error) {
     // aggregate half_hours completed for each day for the year
     query := fmt.Sprintf(`
     WITH RECURSIVE all_dates AS (
@@ -128,11 +205,16 @@ func getYearlyData(db *sql.DB, year int) ([]TaskTrackingAggregate, error) {
     )
     SELECT a.date, COUNT(t.date) as task_count
     FROM all_dates a
-    LEFT JOIN task_tracking t ON a.date = t.date
+    LEFT JOIN (
+        SELECT tt.date FROM task_tracking tt
+        LEFT JOIN tasks ON tasks.id = tt.task_id
+        LEFT JOIN projects p ON p.id = tasks.project_id
+        WHERE ? = '' OR p.name = ?
+    ) t ON a.date = t.date
     GROUP BY a.date
     ORDER BY a.date;`, year, year)
 
-    rows, err := db.Query(query)
+    rows, err := db.Query(query, project, project)
     if err != nil {
         return nil, err
     }
@@ -165,25 +247,55 @@ func getYearlyData(db *sql.DB, year int) ([]TaskTrackingAggregate, error) {
 }
 
 // Function to fetch tasks from the database
-func getTasks(days int, status string) ([]Task, error) {
-    var query string
-    var rows *sql.Rows
-    var err error
+func getTasks(days int, status string, now time.Time) ([]Task, error) {
+    return getFilteredTasks(TaskFilter{Days: days, Now: now, Status: status})
+}
 
-    // Build query based on status
-    if status == "all" {
-        query = fmt.Sprintf("SELECT * FROM tasks WHERE created_at >= date('now', '-%d days')", days)
-    } else if status == "wip" || status == "inprogress" {
-        query = fmt.Sprintf("SELECT * FROM tasks WHERE created_at >= date('now', '-%d days') AND done = 0 AND actual > 0", days)
-    } else if status == "todo" {
-        query = fmt.Sprintf("SELECT * FROM tasks WHERE created_at >= date('now', '-%d days') AND done = 0 AND actual = 0", days)
-    } else if status == "done" {
-        query = fmt.Sprintf("SELECT * FROM tasks WHERE created_at >= date('now', '-%d days') AND done = 1", days)
+// getFilteredTasks fetches the tasks matching every condition of the filter
+func getFilteredTasks(filter TaskFilter) ([]Task, error) {
+    var conditions []string
+    var args []interface{}
+    if filter.ID > 0 {
+        conditions = append(conditions, "t.id = ?")
+        args = append(args, filter.ID)
     } else {
+        conditions = append(conditions, "t.created_at >= ?")
+        args = append(args, formatStoredTime(startOfDay(filter.Now).AddDate(0, 0, -filter.Days)))
+    }
+
+    // Build query based on status
+    switch filter.Status {
+    case "all":
+    case "wip", "inprogress":
+        conditions = append(conditions, "t.done = 0 AND t.actual > 0")
+    case "todo":
+        conditions = append(conditions, "t.done = 0 AND t.actual = 0")
+    case "done":
+        conditions = append(conditions, "t.done = 1")
+    default:
         return nil, fmt.Errorf("invalid status filter")
     }
 
-    rows, err = db.Query(query)
+    if filter.Project != "" {
+        conditions = append(conditions, "p.name = ?")
+        args = append(args, filter.Project)
+    }
+
+    if filter.Tag != "" {
+        conditions = append(conditions, `EXISTS (
+        SELECT 1 FROM task_tags tt JOIN tags g ON g.id = tt.tag_id
+        WHERE tt.task_id = t.id AND g.name = ?)`)
+        args = append(args, normalizeTag(filter.Tag))
+    }
+
+    query := `
+    SELECT t.id, t.name, t.estimate, t.actual, t.created_at, t.updated_at, t.done, COALESCE(p.name, ''),
+        COALESCE(CAST(t.due_date AS TEXT), ''), COALESCE(t.notes, '')
+    FROM tasks t
+    LEFT JOIN projects p ON p.id = t.project_id
+    WHERE ` + strings.Join(conditions, " AND ")
+
+    rows, err := db.Query(query, args...)
     if err != nil {
         return nil, err
     }
@@ -195,29 +307,50 @@ func getTasks(days int, status string) ([]Task, error) {
     // Fetch data from rows
     for rows.Next() {
         var task Task
-        err := rows.Scan(&task.ID, &task.Name, &task.Estimate, &task.Actual, &task.CreatedAt, &task.UpdatedAt, &task.Done)
+        err := rows.Scan(&task.ID, &task.Name, &task.Estimate, &task.Actual, &task.CreatedAt, &task.UpdatedAt, &task.Done, &task.Project, &task.DueDate, &task.Notes)
         if err != nil {
             return nil, err
         }
 
-        // Determine task status
-        if task.Actual > 0 {
-            task.Status = "In Progress"
-        } else {
-            task.Status = "To Do"
-        }
-        if task.Done {
-            task.Status = "Done"
-        }
+        task.CreatedAt, task.UpdatedAt = task.CreatedAt.Local(), task.UpdatedAt.Local()
+        task.Status = taskStatus(task.Actual, task.Done)
 
         tasks = append(tasks, task)
     }
 
-    return tasks, nil
+    return tasks, rows.Err()
+}
+
+// taskStatus describes how far along a task is
+func taskStatus(actual int, done bool) string {
+    if done {
+        return "Done"
+    }
+    if actual > 0 {
+        return "In Progress"
+    }
+    return "To Do"
 }
 
 func getTasksForDay(date string) ([]TaskTracking, error) {
-    rows, err := db.Query(`SELECT task_id, date, half_hour, status FROM task_tracking WHERE date = ?`, date)
+    return getProjectTasksForDay(date, "")
+}
+
+// getProjectTasksForDay returns the tracking rows of a day, limited to the tasks
+// of a project unless project is empty. Each row carries the planned length of
+// the latest session started with it.
+func getProjectTasksForDay(date string, project string) ([]TaskTracking, error) {
+    query := `
+    SELECT tt.task_id, CAST(tt.date AS TEXT), tt.half_hour, tt.status, tt.started_at, tt.ended_at, s.planned_seconds
+    FROM task_tracking tt
+    LEFT JOIN sessions s ON s.id = (
+        SELECT id FROM sessions
+        WHERE task_id = tt.task_id AND started_at >= tt.started_at
+        ORDER BY started_at DESC, id DESC LIMIT 1)
+    LEFT JOIN tasks t ON t.id = tt.task_id
+    LEFT JOIN projects p ON p.id = t.project_id
+    WHERE tt.date = ? AND (? = '' OR p.name = ?)`
+    rows, err := db.Query(query, date, project, project)
     if err != nil {
         return nil, err
     }
@@ -226,39 +359,109 @@ func getTasksForDay(date string) ([]TaskTracking, error) {
     var tasks []TaskTracking
     for rows.Next() {
         var task TaskTracking
-        err := rows.Scan(&task.TaskID, &task.Date, &task.HalfHour, &task.Status)
+        var startedAt, endedAt sql.NullTime
+        var plannedSeconds sql.NullInt64
+        err := rows.Scan(&task.TaskID, &task.Date, &task.HalfHour, &task.Status, &startedAt, &endedAt, &plannedSeconds)
         if err != nil {
             return nil, err
         }
+        task.Planned = time.Duration(plannedSeconds.Int64) * time.Second
+        if startedAt.Valid {
+            local := startedAt.Time.Local()
+            task.StartedAt = &local
+        }
+        if endedAt.Valid {
+            local := endedAt.Time.Local()
+            task.EndedAt = &local
+        }
         tasks = append(tasks, task)
     }
 
     return tasks, nil
 }
 
-func addTask(db *sql.DB, name string, estimate int) error {
-    if name == "" {
-        return fmt.Errorf("task name cannot be empty")
+func addTask(db *sql.DB, name string, estimate int, now time.Time) error {
+    return addTaskWithDetails(db, Task{Name: name, Estimate: estimate}, now)
+}
+
+// addTaskWithDetails adds a task, optionally in a project, and prints a confirmation
+func addTaskWithDetails(db *sql.DB, task Task, now time.Time) error {
+    id, err := createTask(db, task, now)
+    if err != nil {
+        return err
+    }
+    printAddedTask(task, id)
+    return nil
+}
+
+// printAddedTask confirms a new task the same way for 'add' and 'load'
+func printAddedTask(task Task, id int) {
+    estimateSprouts := generateEmojis(task.Estimate, "🌱")
+    fmt.Printf("Added task: %s\nID: %d\nEstimate: %d %s\n", task.Name, id, task.Estimate, estimateSprouts)
+    if task.Project != "" {
+        fmt.Printf("Project: %s\n", task.Project)
+    }
+    if len(task.Tags) > 0 {
+        fmt.Printf("Tags: %s\n", formatTags(task.Tags))
     }
+}
 
-    now := time.Now().Local()
+// createTask inserts a task created at now with its tags and returns its ID.
+// Unless it is part of the caller's transaction, it runs in its own so a task is
+// never left without its tags.
+func createTask(db execer, task Task, now time.Time) (int, error) {
+    if task.Name == "" {
+        return 0, fmt.Errorf("task name cannot be empty")
+    }
 
-    query := `INSERT INTO tasks (name, estimate, actual, created_at, updated_at, done) 
-    VALUES (?, ?, 0, ?, ?, 0)`
+    if conn, ok := db.(*sql.DB); ok {
+        tx, err := conn.Begin()
+        if err != nil {
+            return 0, fmt.Errorf("failed to begin adding task: %v", err)
+        }
+        defer tx.Rollback()
+
+        id, err := createTask(tx, task, now)
+        if err != nil {
+            return 0, err
+        }
+        if err := tx.Commit(); err != nil {
+            return 0, fmt.Errorf("failed to commit task: %v", err)
+        }
+        return id, nil
+    }
+
+    var projectID sql.NullInt64
+    if task.Project != "" {
+        project, err := getProjectByName(db, task.Project)
+        if err != nil {
+            return 0, err
+        }
+        if project.Archived {
+            return 0, fmt.Errorf("project %s is archived", task.Project)
+        }
+        projectID = sql.NullInt64{Int64: int64(project.ID), Valid: true}
+    }
+
+    createdAt := formatStoredTime(now)
+
+    query := `INSERT INTO tasks (name, estimate, actual, created_at, updated_at, done, project_id, due_date, notes) 
+    VALUES (?, ?, 0, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''))`
     
-    result, err := db.Exec(query, name, estimate, now.Format("2006-01-02 15:04:05"), now.Format("2006-01-02 15:04:05"))
+    result, err := db.Exec(query, task.Name, task.Estimate, createdAt, createdAt, task.Done, projectID, task.DueDate, task.Notes)
     if err != nil {
-        return fmt.Errorf("failed to add task: %v", err)
+        return 0, fmt.Errorf("failed to add task: %v", err)
     }
     
     id, err := result.LastInsertId()
     if err != nil {
-        return fmt.Errorf("failed to get the ID of the inserted task: %v", err)
+        return 0, fmt.Errorf("failed to get the ID of the inserted task: %v", err)
     }
-    
-    estimateSprouts := generateEmojis(estimate, "🌱")
-    fmt.Printf("Added task: %s\nID: %d\nEstimate: %d %s\n", name, id, estimate, estimateSprouts)
-    return nil
+
+    if err := tagTask(db, int(id), task.Tags); err != nil {
+        return 0, err
+    }
+    return int(id), nil
 }
 
 func insertTrackingTask(id int, currentDate string, halfHour int) error {
@@ -276,10 +479,29 @@ func insertTrackingTask(id int, currentDate string, halfHour int) error {
     return nil
 }
 
-func updateActual(id int) error {
+// startTracking tracks a task in the half hour a pomodoro starts in and records
+// when it started. A second pomodoro in the same half hour reopens the row, which
+// then spans from the first start to the last end.
+func startTracking(db execer, id int, startedAt time.Time) error {
+    query := `
+    INSERT INTO task_tracking (task_id, date, half_hour, status, started_at)
+    VALUES (?, ?, ?, 'active', ?)
+    ON CONFLICT(task_id, date, half_hour)
+    DO UPDATE SET status = 'done', started_at = COALESCE(task_tracking.started_at, excluded.started_at), ended_at = NULL;
+    `
+    local := startedAt.Local()
+    _, err := db.Exec(query, id, formatDate(local), getHalfHour(local.Hour(), local.Minute()), formatStoredTime(startedAt))
+    if err != nil {
+        return fmt.Errorf("failed to insert tracking task: %v", err)
+    }
+
+    return nil
+}
+
+func updateActual(db execer, id int, now time.Time) error {
 
-    query := `UPDATE tasks SET actual = actual + 1, updated_at = datetime('now', 'localtime') WHERE id = ?`
-    result, err := db.Exec(query, id)
+    query := `UPDATE tasks SET actual = actual + 1, updated_at = ? WHERE id = ?`
+    result, err := db.Exec(query, formatStoredTime(now), id)
     if err != nil {
         return fmt.Errorf("failed to execute update query: %w", err)
     }
@@ -298,9 +520,9 @@ func updateActual(id int) error {
     return nil
 }
 
-func updateEstimate(id int, newEstimate int) error {
-    query := `UPDATE tasks SET estimate = ?, updated_at = datetime('now', 'localtime') WHERE id = ?`
-    result, err := db.Exec(query, newEstimate, id)
+func updateEstimate(id int, newEstimate int, now time.Time) error {
+    query := `UPDATE tasks SET estimate = ?, updated_at = ? WHERE id = ?`
+    result, err := db.Exec(query, newEstimate, formatStoredTime(now), id)
     if err != nil {
         return fmt.Errorf("failed to execute update query: %w", err)
     }
@@ -319,10 +541,10 @@ func updateEstimate(id int, newEstimate int) error {
     return nil
 }
 
-func markAsDone(id int) error {
-    query := `UPDATE tasks SET done = 1, updated_at =  datetime('now', 'localtime') WHERE id = ?`
+func markAsDone(id int, now time.Time) error {
+    query := `UPDATE tasks SET done = 1, updated_at = ? WHERE id = ?`
     
-    result, err := db.Exec(query, id)
+    result, err := db.Exec(query, formatStoredTime(now), id)
     if err != nil {
         return fmt.Errorf("failed to execute update query: %w", err)
     }
@@ -344,22 +566,420 @@ func markAsDone(id int) error {
 
 // delete a task
 func deleteTask(db *sql.DB, id int) error {
-    query := `DELETE FROM tasks WHERE id = ?`
-    result, err := db.Exec(query, id)
+    deleted, err := removeTask(db, id)
+    if err != nil {
+        return err
+    }
+
+    if !deleted {
+        fmt.Printf("No task found with ID: %d\n", id)
+    } else {
+        fmt.Printf("Task with ID: %d has been deleted\n", id)
+    }
+
+    return nil
+}
+
+// removeTask deletes a task, its tracking rows and sessions go with it. It
+// reports false when there was no such task.
+func removeTask(db *sql.DB, id int) (bool, error) {
+    result, err := db.Exec(`DELETE FROM tasks WHERE id = ?`, id)
     if err != nil {
-        return fmt.Errorf("failed to delete task: %v", err)
+        return false, fmt.Errorf("failed to delete task: %v", err)
     }
 
     rowsAffected, err := result.RowsAffected()
     if err != nil {
-        return fmt.Errorf("failed to retrieve rows affected: %v", err)
+        return false, fmt.Errorf("failed to retrieve rows affected: %v", err)
     }
+    return rowsAffected > 0, nil
+}
+
+// getTask returns a single task with its interruptions and tags, or sql.ErrNoRows
+func getTask(db *sql.DB, id int) (Task, error) {
+    tasks, err := getFilteredTasks(TaskFilter{ID: id, Status: "all"})
+    if err != nil {
+        return Task{}, err
+    }
+    if len(tasks) == 0 {
+        return Task{}, sql.ErrNoRows
+    }
+    if err := addInterruptionCounts(db, tasks); err != nil {
+        return Task{}, err
+    }
+    if err := addTaskTags(db, tasks); err != nil {
+        return Task{}, err
+    }
+    return tasks[0], nil
+}
+
+// TaskChanges lists the fields of a task to change, nil fields are left alone
+type TaskChanges struct {
+    Name     *string `json:"name"`
+    Estimate *int    `json:"estimate"`
+    Done     *bool   `json:"done"`
+    DueDate  *string `json:"due_date"`
+    Notes    *string `json:"notes"`
+}
+
+// changeTask applies the changes to a task at now. It reports false when there
+// was no such task.
+func changeTask(db *sql.DB, id int, changes TaskChanges, now time.Time) (bool, error) {
+    sets := []string{"updated_at = ?"}
+    args := []interface{}{formatStoredTime(now)}
+
+    if changes.Name != nil {
+        if *changes.Name == "" {
+            return false, fmt.Errorf("task name cannot be empty")
+        }
+        sets = append(sets, "name = ?")
+        args = append(args, *changes.Name)
+    }
+    if changes.Estimate != nil {
+        if *changes.Estimate < 0 {
+            return false, fmt.Errorf("estimate cannot be negative")
+        }
+        sets = append(sets, "estimate = ?")
+        args = append(args, *changes.Estimate)
+    }
+    if changes.Done != nil {
+        sets = append(sets, "done = ?")
+        args = append(args, *changes.Done)
+    }
+    if changes.DueDate != nil {
+        if *changes.DueDate != "" {
+            if _, err := time.Parse("2006-01-02", *changes.DueDate); err != nil {
+                return false, fmt.Errorf("invalid due date %q, expected YYYY-MM-DD", *changes.DueDate)
+            }
+        }
+        sets = append(sets, "due_date = NULLIF(?, '')")
+        args = append(args, *changes.DueDate)
+    }
+    if changes.Notes != nil {
+        sets = append(sets, "notes = NULLIF(?, '')")
+        args = append(args, *changes.Notes)
+    }
+
+    result, err := db.Exec(`UPDATE tasks SET `+strings.Join(sets, ", ")+` WHERE id = ?`, append(args, id)...)
+    if err != nil {
+        return false, fmt.Errorf("failed to update task: %v", err)
+    }
+    rowsAffected, err := result.RowsAffected()
+    if err != nil {
+        return false, fmt.Errorf("failed to retrieve rows affected: %v", err)
+    }
+    return rowsAffected > 0, nil
+}
 
+// errPomodoroRunning is returned by startOnlySession when a session is already open
+var errPomodoroRunning = errors.New("a pomodoro is already running")
+
+// startOnlySession is startSession unless a session is already open. The check
+// and the insert are a single statement, so two callers cannot both open one.
+func startOnlySession(db execer, taskID int, planned time.Duration, startedAt time.Time) (int, error) {
+    query := `
+    INSERT INTO sessions (task_id, started_at, planned_seconds)
+    SELECT ?, ?, ? WHERE NOT EXISTS (SELECT 1 FROM sessions WHERE ended_at IS NULL)`
+    result, err := db.Exec(query, taskID, formatStoredTime(startedAt), int(planned.Seconds()))
+    if err != nil {
+        return 0, fmt.Errorf("failed to start session: %v", err)
+    }
+
+    rowsAffected, err := result.RowsAffected()
+    if err != nil {
+        return 0, fmt.Errorf("failed to retrieve rows affected: %v", err)
+    }
     if rowsAffected == 0 {
-        fmt.Printf("No task found with ID: %d\n", id)
-    } else {
-        fmt.Printf("Task with ID: %d has been deleted\n", id)
+        return 0, errPomodoroRunning
+    }
+    id, err := result.LastInsertId()
+    if err != nil {
+        return 0, fmt.Errorf("failed to get the ID of the inserted session: %v", err)
+    }
+    return int(id), nil
+}
+
+// startSession records the start of a pomodoro and returns the new session ID
+func startSession(db *sql.DB, taskID int, planned time.Duration, startedAt time.Time) (int, error) {
+    query := `INSERT INTO sessions (task_id, started_at, planned_seconds) VALUES (?, ?, ?)`
+    result, err := db.Exec(query, taskID, formatStoredTime(startedAt), int(planned.Seconds()))
+    if err != nil {
+        return 0, fmt.Errorf("failed to start session: %v", err)
+    }
+
+    id, err := result.LastInsertId()
+    if err != nil {
+        return 0, fmt.Errorf("failed to get the ID of the inserted session: %v", err)
+    }
+    return int(id), nil
+}
+
+// endSession closes a running session with the given outcome. It reports false
+// if the session had already been closed, e.g. by 'interrupt' from another shell.
+func endSession(db execer, id int, endedAt time.Time, outcome, interruption, reason string) (bool, error) {
+    query := `
+    UPDATE sessions SET ended_at = ?, outcome = ?, interruption = NULLIF(?, ''), reason = NULLIF(?, '')
+    WHERE id = ? AND ended_at IS NULL`
+    result, err := db.Exec(query, formatStoredTime(endedAt), outcome, interruption, reason, id)
+    if err != nil {
+        return false, fmt.Errorf("failed to end session: %v", err)
+    }
+
+    rowsAffected, err := result.RowsAffected()
+    if err != nil {
+        return false, fmt.Errorf("failed to retrieve rows affected: %v", err)
+    }
+    if rowsAffected == 0 {
+        return false, nil
+    }
+
+    // the tracking row of the pomodoro ends with it, whatever the outcome
+    query = `
+    UPDATE task_tracking SET ended_at = ?
+    WHERE started_at IS NOT NULL AND ended_at IS NULL AND task_id = (SELECT task_id FROM sessions WHERE id = ?)`
+    if _, err := db.Exec(query, formatStoredTime(endedAt), id); err != nil {
+        return false, fmt.Errorf("failed to end tracking: %v", err)
+    }
+    return true, nil
+}
+
+// getOpenSession returns the most recent running session for a task, or for
+// any task when taskID is 0
+func getOpenSession(db *sql.DB, taskID int) (Session, error) {
+    query := `
+    SELECT s.id, s.task_id, s.started_at, s.ended_at, s.planned_seconds, COALESCE(s.outcome, ''), COALESCE(s.interruption, ''),
+        COALESCE(s.reason, ''), COALESCE(t.name, '')
+    FROM sessions s
+    LEFT JOIN tasks t ON t.id = s.task_id
+    WHERE (? = 0 OR s.task_id = ?) AND s.ended_at IS NULL
+    ORDER BY s.started_at DESC, s.id DESC
+    LIMIT 1`
+
+    var session Session
+    var plannedSeconds int
+    err := db.QueryRow(query, taskID, taskID).Scan(&session.ID, &session.TaskID, &session.StartedAt, &session.EndedAt,
+        &plannedSeconds, &session.Outcome, &session.Interruption, &session.Reason, &session.TaskName)
+    if err != nil {
+        return Session{}, err
+    }
+    session.Planned = time.Duration(plannedSeconds) * time.Second
+    return session, nil
+}
+
+// getRunningSession returns the pomodoro that is running, whichever shell started
+// it. It returns sql.ErrNoRows when there is none.
+func getRunningSession(db *sql.DB) (Session, error) {
+    return getOpenSession(db, 0)
+}
+
+// interruptTask ends the running pomodoro of a task as interrupted
+func interruptTask(db *sql.DB, taskID int, kind, reason string, endedAt time.Time) error {
+    if kind != "internal" && kind != "external" {
+        return fmt.Errorf("invalid interruption kind %q, expected 'internal' or 'external'", kind)
+    }
+
+    session, err := getOpenSession(db, taskID)
+    if err == sql.ErrNoRows {
+        return fmt.Errorf("no running pomodoro found for task ID: %d", taskID)
+    } else if err != nil {
+        return fmt.Errorf("failed to find running pomodoro: %v", err)
+    }
+
+    _, err = endSession(db, session.ID, endedAt, "interrupted", kind, reason)
+    return err
+}
+
+// addInterruptionCounts fills in the internal and external interruption counts of each task
+func addInterruptionCounts(db *sql.DB, tasks []Task) error {
+    query := `
+    SELECT task_id,
+        SUM(CASE WHEN interruption = 'internal' THEN 1 ELSE 0 END),
+        SUM(CASE WHEN interruption = 'external' THEN 1 ELSE 0 END)
+    FROM sessions
+    WHERE outcome = 'interrupted'
+    GROUP BY task_id`
+
+    rows, err := db.Query(query)
+    if err != nil {
+        return fmt.Errorf("failed to count interruptions: %v", err)
+    }
+    defer rows.Close()
+
+    counts := make(map[int][2]int)
+    for rows.Next() {
+        var taskID, internal, external int
+        if err := rows.Scan(&taskID, &internal, &external); err != nil {
+            return err
+        }
+        counts[taskID] = [2]int{internal, external}
+    }
+    if err := rows.Err(); err != nil {
+        return err
+    }
+
+    for i := range tasks {
+        tasks[i].InternalInterruptions = counts[tasks[i].ID][0]
+        tasks[i].ExternalInterruptions = counts[tasks[i].ID][1]
+    }
+    return nil
+}
+
+// startBreak records the start of a break and returns the new break ID
+func startBreak(db *sql.DB, kind string, planned time.Duration, startedAt time.Time) (int, error) {
+    query := `INSERT INTO breaks (kind, date, half_hour, started_at, planned_seconds) VALUES (?, ?, ?, ?, ?)`
+    local := startedAt.Local()
+    result, err := db.Exec(query, kind, formatDate(local), getHalfHour(local.Hour(), local.Minute()),
+        formatStoredTime(startedAt), int(planned.Seconds()))
+    if err != nil {
+        return 0, fmt.Errorf("failed to start break: %v", err)
+    }
+
+    id, err := result.LastInsertId()
+    if err != nil {
+        return 0, fmt.Errorf("failed to get the ID of the inserted break: %v", err)
+    }
+    return int(id), nil
+}
+
+// endBreak records when a break finished, whether it ran its full length or not
+func endBreak(db *sql.DB, id int, endedAt time.Time) error {
+    _, err := db.Exec(`UPDATE breaks SET ended_at = ? WHERE id = ? AND ended_at IS NULL`, formatStoredTime(endedAt), id)
+    if err != nil {
+        return fmt.Errorf("failed to end break: %v", err)
+    }
+    return nil
+}
+
+// getRunningBreak returns the break that has not ended yet, or sql.ErrNoRows
+func getRunningBreak(db *sql.DB) (Break, error) {
+    query := `
+    SELECT id, kind, date, half_hour, started_at, ended_at, planned_seconds FROM breaks
+    WHERE ended_at IS NULL
+    ORDER BY started_at DESC, id DESC
+    LIMIT 1`
+
+    var b Break
+    var plannedSeconds int
+    err := db.QueryRow(query).Scan(&b.ID, &b.Kind, &b.Date, &b.HalfHour, &b.StartedAt, &b.EndedAt, &plannedSeconds)
+    if err != nil {
+        return Break{}, err
+    }
+    b.Planned = time.Duration(plannedSeconds) * time.Second
+    return b, nil
+}
+
+func getBreaksForDay(date string) ([]Break, error) {
+    rows, err := db.Query(`SELECT id, kind, date, half_hour, started_at, ended_at, planned_seconds FROM breaks WHERE date = ?`, date)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var breaks []Break
+    for rows.Next() {
+        var b Break
+        var plannedSeconds int
+        err := rows.Scan(&b.ID, &b.Kind, &b.Date, &b.HalfHour, &b.StartedAt, &b.EndedAt, &plannedSeconds)
+        if err != nil {
+            return nil, err
+        }
+        b.Planned = time.Duration(plannedSeconds) * time.Second
+        breaks = append(breaks, b)
+    }
+
+    return breaks, rows.Err()
+}
+
+// countPomodorosSinceLongBreak counts the pomodoros completed on the given day
+// since the last long break, which decides when the next long break is due
+func countPomodorosSinceLongBreak(db *sql.DB, date string) (int, error) {
+    query := `
+    SELECT COUNT(*) FROM sessions
+    WHERE outcome = 'completed'
+    AND started_at >= ? AND started_at < ?
+    AND started_at > COALESCE(
+        (SELECT MAX(started_at) FROM breaks WHERE kind = 'long' AND date = ?), '')`
+
+    start, end, err := storedDayRange(date, date)
+    if err != nil {
+        return 0, err
     }
+    var count int
+    err = db.QueryRow(query, start, end, date).Scan(&count)
+    if err != nil {
+        return 0, fmt.Errorf("failed to count pomodoros: %v", err)
+    }
+    return count, nil
+}
 
+// getSetting returns the stored value of a setting, or fallback when it has not been set
+func getSetting(db *sql.DB, key string, fallback string) (string, error) {
+    var value string
+    err := db.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&value)
+    if err == sql.ErrNoRows {
+        return fallback, nil
+    } else if err != nil {
+        return "", fmt.Errorf("failed to read setting %s: %v", key, err)
+    }
+    return value, nil
+}
+
+func setSetting(db *sql.DB, key string, value string) error {
+    query := `
+    INSERT INTO settings (key, value) VALUES (?, ?)
+    ON CONFLICT(key) DO UPDATE SET value = excluded.value`
+    _, err := db.Exec(query, key, value)
+    if err != nil {
+        return fmt.Errorf("failed to save setting %s: %v", key, err)
+    }
     return nil
+}
+
+// taskIDExists reports whether a task with the given ID exists
+func taskIDExists(db *sql.DB, id int) (bool, error) {
+    var count int
+    err := db.QueryRow(`SELECT COUNT(*) FROM tasks WHERE id = ?`, id).Scan(&count)
+    if err != nil {
+        return false, fmt.Errorf("failed to look up task: %v", err)
+    }
+    return count > 0, nil
+}
+
+// findOrphans lists rows left behind by tasks deleted while foreign keys were not enforced
+func findOrphans(db *sql.DB) ([]Orphan, error) {
+    rows, err := db.Query(`PRAGMA foreign_key_check`)
+    if err != nil {
+        return nil, fmt.Errorf("failed to check foreign keys: %v", err)
+    }
+    defer rows.Close()
+
+    var orphans []Orphan
+    for rows.Next() {
+        var orphan Orphan
+        var fkid int
+        if err := rows.Scan(&orphan.Table, &orphan.RowID, &orphan.Parent, &fkid); err != nil {
+            return nil, err
+        }
+        orphans = append(orphans, orphan)
+    }
+    return orphans, rows.Err()
+}
+
+// deleteOrphans removes the given orphaned rows in a single transaction
+func deleteOrphans(db *sql.DB, orphans []Orphan) error {
+    tx, err := db.Begin()
+    if err != nil {
+        return fmt.Errorf("failed to begin repair: %v", err)
+    }
+    defer tx.Rollback()
+
+    for _, orphan := range orphans {
+        // the table name comes from sqlite itself, not from user input
+        query := fmt.Sprintf(`DELETE FROM %q WHERE rowid = ?`, orphan.Table)
+        if _, err := tx.Exec(query, orphan.RowID); err != nil {
+            return fmt.Errorf("failed to delete orphaned row %d from %s: %v", orphan.RowID, orphan.Table, err)
+        }
+    }
+
+    return tx.Commit()
 }
\ No newline at end of file
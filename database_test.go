@@ -21,35 +21,10 @@ func setupTestDB2(t *testing.T) {
         t.Fatalf("failed to open test database: %v", err)
     }
 
-    // Create tasks table if it doesn't exist
-    createTableQuery := `
-    CREATE TABLE IF NOT EXISTS tasks (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        name TEXT NOT NULL,
-        estimate INTEGER NOT NULL,
-        actual INTEGER DEFAULT 0,
-        created_at DATETIME DEFAULT (datetime('now', 'localtime')),
-        updated_at DATETIME DEFAULT (datetime('now', 'localtime')),
-        done BOOLEAN DEFAULT 0
-    );`
-    _, err = testDB.Exec(createTableQuery)
-    if err != nil {
-        t.Fatalf("failed to create task_tracking table: %v", err)
-    }
-
-    // Create the task_tracking table schema for testing
-    query := `
-    CREATE TABLE task_tracking (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        task_id INTEGER NOT NULL,
-        date TEXT NOT NULL,
-        half_hour INTEGER NOT NULL,
-        status TEXT NOT NULL,
-        UNIQUE(task_id, date, half_hour)
-    );`
-    _, err = testDB.Exec(query)
-    if err != nil {
-        t.Fatalf("failed to create task_tracking table: %v", err)
+    // Create the schema from the migrations, without enforcing foreign keys so
+    // tracking rows can be tested without creating their tasks first
+    if _, err := migrateDatabase(testDB, false); err != nil {
+        t.Fatalf("failed to create test schema: %v", err)
     }
 }
 
@@ -134,12 +109,12 @@ func TestGetDailyTasks(t *testing.T) {
     setupTestDB2(t) // Initialize the test database
     db = testDB
 
-    err := addTask(db, "Task 1", 1)
+    err := addTask(db, "Task 1", 1, time.Now())
     if err != nil {
         t.Fatalf("failed to add task: %v", err)
     }
 
-    tasks, _ := getDailyTasks(db)
+    tasks, _ := getDailyTasks(db, time.Now())
     if len(tasks) != 1 {
         t.Errorf("expected 1 task, got %d", len(tasks))
     }
@@ -150,12 +125,12 @@ func TestMarkAsDone(t *testing.T) {
     defer db.Close()
     db = testDB
 
-    err := addTask(db, "Task 1", 1)
+    err := addTask(db, "Task 1", 1, time.Now())
     if err != nil {
         t.Fatalf("failed to add task: %v", err)
     }
 
-    err = markAsDone(1)
+    err = markAsDone(1, time.Now())
     if err != nil {
         t.Fatalf("failed to mark task as done: %v", err)
     }
@@ -183,7 +158,7 @@ func TestGetTasks(t *testing.T) {
     insertTestTask(db, "Task 3", 4, 4, true)  // Done task
 
     // Test case 1: Status "all"
-    tasks, err := getTasks(7, "all")
+    tasks, err := getTasks(7, "all", time.Now())
     if err != nil {
         t.Fatalf("unexpected error: %v", err)
     }
@@ -192,7 +167,7 @@ func TestGetTasks(t *testing.T) {
     }
 
     // Test case 2: Status "wip"
-    tasks, err = getTasks(7, "wip")
+    tasks, err = getTasks(7, "wip", time.Now())
     if err != nil {
         t.Fatalf("unexpected error: %v", err)
     }
@@ -204,7 +179,7 @@ func TestGetTasks(t *testing.T) {
     }
 
     // Test case 3: Status "todo"
-    tasks, err = getTasks(7, "todo")
+    tasks, err = getTasks(7, "todo", time.Now())
     if err != nil {
         t.Fatalf("unexpected error: %v", err)
     }
@@ -216,7 +191,7 @@ func TestGetTasks(t *testing.T) {
     }
 
     // Test case 4: Status "done"
-    tasks, err = getTasks(7, "done")
+    tasks, err = getTasks(7, "done", time.Now())
     if err != nil {
         t.Fatalf("unexpected error: %v", err)
     }
@@ -228,7 +203,7 @@ func TestGetTasks(t *testing.T) {
     }
 
     // Test case 5: Invalid status
-    _, err = getTasks(7, "invalid")
+    _, err = getTasks(7, "invalid", time.Now())
     if err == nil {
         t.Error("expected error for invalid status, but got none")
     }
@@ -241,7 +216,7 @@ func TestUpdateEstimate(t *testing.T) {
 
     insertTestTask(db, "Task 1", 5, 2, false) // WIP task
 
-    err := updateEstimate(1, 7)
+    err := updateEstimate(1, 7, time.Now())
     if err != nil {
         t.Fatalf("unexpected error: %v", err)
     }
@@ -265,7 +240,7 @@ func TestUpdateActual(t *testing.T) {
 
     insertTestTask(db, "Task 1", 5, 2, false) // WIP task
 
-    err := updateActual(1)
+    err := updateActual(db, 1, time.Now())
     if err != nil {
         t.Fatalf("unexpected error: %v", err)
     }
@@ -338,4 +313,380 @@ func TestGetYearlyData(t *testing.T) {
     }
 
 
-}
\ No newline at end of file
+}
+func TestInterruptTask(t *testing.T) {
+    db = initializeDatabase(":memory:")
+    defer db.Close()
+
+    if err := addTask(db, "Task 1", 2, time.Now()); err != nil {
+        t.Fatalf("failed to add task: %v", err)
+    }
+
+    // no pomodoro is running yet
+    if err := interruptTask(db, 1, "internal", "", time.Now()); err == nil {
+        t.Error("expected an error when no pomodoro is running, but got none")
+    }
+
+    sessionID, err := startSession(db, 1, 25*time.Minute, time.Now())
+    if err != nil {
+        t.Fatalf("failed to start session: %v", err)
+    }
+
+    if err := interruptTask(db, 1, "external", "phone call", time.Now()); err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+
+    // the timer finishing afterwards must not complete the interrupted session
+    completed, err := endSession(db, sessionID, time.Now(), "completed", "", "")
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if completed {
+        t.Error("expected the interrupted session to stay interrupted")
+    }
+
+    tasks := []Task{{ID: 1}}
+    if err := addInterruptionCounts(db, tasks); err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if tasks[0].ExternalInterruptions != 1 || tasks[0].InternalInterruptions != 0 {
+        t.Errorf("expected 0 internal and 1 external interruption, got %d and %d",
+            tasks[0].InternalInterruptions, tasks[0].ExternalInterruptions)
+    }
+}
+
+func TestGetRunningSession(t *testing.T) {
+    db = initializeDatabase(":memory:")
+    defer db.Close()
+
+    if err := addTask(db, "Task 1", 2, time.Now()); err != nil {
+        t.Fatalf("failed to add task: %v", err)
+    }
+    if _, err := getRunningSession(db); err != sql.ErrNoRows {
+        t.Fatalf("expected no running session, got %v", err)
+    }
+
+    startedAt := time.Date(2024, time.September, 21, 10, 0, 0, 0, time.Local)
+    sessionID, err := startSession(db, 1, 25*time.Minute, startedAt)
+    if err != nil {
+        t.Fatalf("failed to start session: %v", err)
+    }
+
+    // the session is found without knowing its task, as 'status' in another shell would
+    session, err := getRunningSession(db)
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if session.ID != sessionID || session.TaskName != "Task 1" || !session.Deadline().Equal(startedAt.Add(25*time.Minute)) {
+        t.Errorf("unexpected running session: %+v", session)
+    }
+
+    if _, err := endSession(db, sessionID, startedAt.Add(time.Minute), "abandoned", "", ""); err != nil {
+        t.Fatalf("failed to end session: %v", err)
+    }
+    if _, err := getRunningSession(db); err != sql.ErrNoRows {
+        t.Errorf("expected no running session after it ended, got %v", err)
+    }
+}
+
+func TestTrackingTimestamps(t *testing.T) {
+    db = initializeDatabase(":memory:")
+    defer db.Close()
+
+    if err := addTask(db, "Task 1", 2, time.Now()); err != nil {
+        t.Fatalf("failed to add task: %v", err)
+    }
+
+    startedAt := time.Date(2024, time.September, 21, 10, 20, 0, 0, time.Local)
+    sessionID, err := beginPomodoro(1, 25*time.Minute, startedAt)
+    if err != nil {
+        t.Fatalf("failed to begin pomodoro: %v", err)
+    }
+    tracking, err := getTasksForDay("2024-09-21")
+    if err != nil || len(tracking) != 1 {
+        t.Fatalf("expected 1 tracking row, got %v %+v", err, tracking)
+    }
+    if tracking[0].HalfHour != 20 || tracking[0].StartedAt == nil || !tracking[0].StartedAt.Equal(startedAt) || tracking[0].EndedAt != nil {
+        t.Errorf("expected a running row in the 10:00 half hour, got %+v", tracking[0])
+    }
+
+    endedAt := startedAt.Add(25 * time.Minute)
+    if _, err := completePomodoro(sessionID, 1, endedAt); err != nil {
+        t.Fatalf("failed to complete pomodoro: %v", err)
+    }
+    tracking, err = getTasksForDay("2024-09-21")
+    if err != nil || len(tracking) != 1 {
+        t.Fatalf("expected 1 tracking row, got %v %+v", err, tracking)
+    }
+    if tracking[0].EndedAt == nil || !tracking[0].EndedAt.Equal(endedAt) {
+        t.Errorf("expected the row to end with the pomodoro, got %+v", tracking[0])
+    }
+}
+
+func TestUpdateCompletesActivatedPomodoro(t *testing.T) {
+    db = initializeDatabase(":memory:")
+    defer db.Close()
+
+    if err := addTask(db, "Task 1", 2, time.Now()); err != nil {
+        t.Fatalf("failed to add task: %v", err)
+    }
+    if _, err := beginPomodoro(1, defaultPomodoroDuration, time.Now()); err != nil {
+        t.Fatalf("failed to begin pomodoro: %v", err)
+    }
+
+    handleUpdateCommand([]string{"--id=1"})
+
+    if _, err := getOpenSession(db, 1); err != sql.ErrNoRows {
+        t.Errorf("expected the session to be closed, got %v", err)
+    }
+    task, err := getTask(db, 1)
+    if err != nil || task.Actual != 1 {
+        t.Errorf("expected an actual of 1, got %d (%v)", task.Actual, err)
+    }
+}
+
+func TestCompletePomodoroRollsBack(t *testing.T) {
+    db = initializeDatabase(":memory:")
+    defer db.Close()
+
+    if err := addTask(db, "Task 1", 2, time.Now()); err != nil {
+        t.Fatalf("failed to add task: %v", err)
+    }
+    sessionID, err := beginPomodoro(1, defaultPomodoroDuration, time.Now())
+    if err != nil {
+        t.Fatalf("failed to begin pomodoro: %v", err)
+    }
+    _, err = db.Exec(`
+    CREATE TRIGGER refuse_count BEFORE UPDATE OF actual ON tasks
+    BEGIN SELECT RAISE(ABORT, 'counting refused'); END;`)
+    if err != nil {
+        t.Fatalf("failed to create trigger: %v", err)
+    }
+
+    if _, err := completePomodoro(sessionID, 1, time.Now()); err == nil {
+        t.Fatal("expected counting to fail")
+    }
+    if _, err := getOpenSession(db, 1); err != nil {
+        t.Errorf("expected the session to stay open with the count, got %v", err)
+    }
+}
+
+func TestCountPomodorosSinceLongBreak(t *testing.T) {
+    db = initializeDatabase(":memory:")
+    defer db.Close()
+
+    if err := addTask(db, "Task 1", 4, time.Now()); err != nil {
+        t.Fatalf("failed to add task: %v", err)
+    }
+
+    day := time.Date(2024, time.September, 21, 9, 0, 0, 0, time.Local)
+    completePomodoro := func(start time.Time) {
+        id, err := startSession(db, 1, 25*time.Minute, start)
+        if err != nil {
+            t.Fatalf("failed to start session: %v", err)
+        }
+        if _, err := endSession(db, id, start.Add(25*time.Minute), "completed", "", ""); err != nil {
+            t.Fatalf("failed to end session: %v", err)
+        }
+    }
+
+    completePomodoro(day)
+    completePomodoro(day.Add(30 * time.Minute))
+    completePomodoro(day.Add(-24 * time.Hour)) // yesterday does not count
+
+    count, err := countPomodorosSinceLongBreak(db, "2024-09-21")
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if count != 2 {
+        t.Errorf("expected 2 pomodoros, got %d", count)
+    }
+
+    if _, err := startBreak(db, "long", 15*time.Minute, day.Add(time.Hour)); err != nil {
+        t.Fatalf("failed to start break: %v", err)
+    }
+    completePomodoro(day.Add(90 * time.Minute))
+
+    count, err = countPomodorosSinceLongBreak(db, "2024-09-21")
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if count != 1 {
+        t.Errorf("expected 1 pomodoro after the long break, got %d", count)
+    }
+}
+
+func TestMigrationsAreOrdered(t *testing.T) {
+    for i, m := range migrations {
+        if m.version != i+1 {
+            t.Errorf("expected migration %d to have version %d, got %d", i, i+1, m.version)
+        }
+    }
+}
+
+// TestMigrateLegacyDatabase migrates a v0 database, as created before schema
+// versioning existed, all the way to the latest version
+func TestMigrateLegacyDatabase(t *testing.T) {
+    legacyDB, err := sql.Open("sqlite3", ":memory:")
+    if err != nil {
+        t.Fatalf("failed to open test database: %v", err)
+    }
+    defer legacyDB.Close()
+
+    _, err = legacyDB.Exec(`
+    CREATE TABLE tasks (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name TEXT NOT NULL,
+        estimate INTEGER NOT NULL,
+        actual INTEGER DEFAULT 0,
+        created_at DATETIME DEFAULT (datetime('now', 'localtime')),
+        updated_at DATETIME DEFAULT (datetime('now', 'localtime')),
+        done BOOLEAN DEFAULT 0
+    );
+    INSERT INTO tasks (name, estimate, actual) VALUES ('Legacy task', 3, 1);`)
+    if err != nil {
+        t.Fatalf("failed to create legacy schema: %v", err)
+    }
+
+    pending, err := migrateDatabase(legacyDB, true)
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if len(pending) != len(migrations) {
+        t.Errorf("expected %d pending migrations, got %d", len(migrations), len(pending))
+    }
+    if tableExists(legacyDB, "sessions") || tableExists(legacyDB, "schema_version") {
+        t.Error("expected a dry run to leave the schema alone")
+    }
+
+    applied, err := migrateDatabase(legacyDB, false)
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if len(applied) != len(migrations) {
+        t.Errorf("expected %d applied migrations, got %d", len(migrations), len(applied))
+    }
+
+    version, err := getSchemaVersion(legacyDB)
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if version != latestSchemaVersion() {
+        t.Errorf("expected schema version %d, got %d", latestSchemaVersion(), version)
+    }
+
+    for _, table := range []string{"tasks", "task_tracking", "sessions", "breaks", "settings"} {
+        if !tableExists(legacyDB, table) {
+            t.Errorf("expected '%s' table to exist after migrating", table)
+        }
+    }
+
+    var name string
+    if err := legacyDB.QueryRow(`SELECT name FROM tasks WHERE id = 1`).Scan(&name); err != nil || name != "Legacy task" {
+        t.Errorf("expected the legacy task to survive the migration, got %q (%v)", name, err)
+    }
+
+    // migrating again is a no-op
+    applied, err = migrateDatabase(legacyDB, false)
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if len(applied) != 0 {
+        t.Errorf("expected no migrations on an up to date database, got %d", len(applied))
+    }
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+    testDB, err := sql.Open("sqlite3", ":memory:")
+    if err != nil {
+        t.Fatalf("failed to open test database: %v", err)
+    }
+    defer testDB.Close()
+
+    original := migrations
+    defer func() { migrations = original }()
+    migrations = append(append([]migration{}, original...), migration{
+        len(original) + 1, "broken migration", execStatements(`ALTER TABLE missing ADD COLUMN nope TEXT`),
+    })
+
+    if _, err := migrateDatabase(testDB, false); err == nil {
+        t.Fatal("expected the broken migration to fail")
+    }
+
+    version, err := getSchemaVersion(testDB)
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if version != 0 || tableExists(testDB, "tasks") {
+        t.Errorf("expected the failed migration to leave a v0 database, got version %d", version)
+    }
+}
+
+func TestDeleteTaskCascades(t *testing.T) {
+    db = initializeDatabase(":memory:")
+    defer db.Close()
+
+    if err := addTask(db, "Task 1", 2, time.Now()); err != nil {
+        t.Fatalf("failed to add task: %v", err)
+    }
+    if err := insertTrackingTask(1, "2024-09-21", 20); err != nil {
+        t.Fatalf("failed to track task: %v", err)
+    }
+    if _, err := startSession(db, 1, 25*time.Minute, time.Now()); err != nil {
+        t.Fatalf("failed to start session: %v", err)
+    }
+
+    // tracking a task that does not exist is rejected
+    if err := insertTrackingTask(99, "2024-09-21", 20); err == nil {
+        t.Error("expected tracking a missing task to fail, but it succeeded")
+    }
+
+    if err := deleteTask(db, 1); err != nil {
+        t.Fatalf("failed to delete task: %v", err)
+    }
+
+    for _, table := range []string{"task_tracking", "sessions"} {
+        var count int
+        if err := db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM %s`, table)).Scan(&count); err != nil {
+            t.Fatalf("failed to query %s: %v", table, err)
+        }
+        if count != 0 {
+            t.Errorf("expected the %s rows to be deleted with the task, got %d", table, count)
+        }
+    }
+}
+
+func TestFindOrphans(t *testing.T) {
+    db = initializeDatabase(":memory:")
+    defer db.Close()
+
+    // simulate a row left behind before foreign keys were enforced
+    if _, err := db.Exec(`PRAGMA foreign_keys = OFF`); err != nil {
+        t.Fatalf("failed to disable foreign keys: %v", err)
+    }
+    if err := insertTrackingTask(42, "2024-09-21", 20); err != nil {
+        t.Fatalf("failed to track task: %v", err)
+    }
+    if _, err := db.Exec(`PRAGMA foreign_keys = ON`); err != nil {
+        t.Fatalf("failed to enable foreign keys: %v", err)
+    }
+
+    orphans, err := findOrphans(db)
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if len(orphans) != 1 || orphans[0].Table != "task_tracking" {
+        t.Fatalf("expected 1 orphaned task_tracking row, got %v", orphans)
+    }
+
+    if err := deleteOrphans(db, orphans); err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    orphans, err = findOrphans(db)
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if len(orphans) != 0 {
+        t.Errorf("expected no orphans after repairing, got %d", len(orphans))
+    }
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// legacyDatabasePath is where tomatillo used to keep its database, relative to
+// whatever directory it happened to be run from.
+const legacyDatabasePath = "./tomatillo.db"
+
+// resolveDatabasePath picks the database location: the --db flag wins, then the
+// TOMATILLO_DB environment variable, then the XDG data directory. Moving an old
+// database is only offered when interactive is set, until then it stays in use.
+func resolveDatabasePath(flagPath string, getenv func(string) string, interactive bool) (string, error) {
+	if flagPath != "" {
+		return flagPath, nil
+	}
+	if envPath := getenv("TOMATILLO_DB"); envPath != "" {
+		return envPath, nil
+	}
+
+	path, err := defaultDatabasePath(getenv)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create data directory: %v", err)
+	}
+	if !interactive {
+		return keepLegacyDatabase(legacyDatabasePath, path, os.Stderr)
+	}
+	if err := offerLegacyDatabaseMove(legacyDatabasePath, path, os.Stdin, os.Stderr); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or a file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// defaultDatabasePath returns $XDG_DATA_HOME/tomatillo/tomatillo.db, falling back
+// to ~/.local/share as the XDG base directory spec describes.
+func defaultDatabasePath(getenv func(string) string) (string, error) {
+	dataHome := getenv("XDG_DATA_HOME")
+	if dataHome == "" || !filepath.IsAbs(dataHome) {
+		home := getenv("HOME")
+		if home == "" {
+			return "", fmt.Errorf("cannot find the home directory, use --db or TOMATILLO_DB")
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "tomatillo", "tomatillo.db"), nil
+}
+
+// pendingLegacyDatabase returns the absolute paths of an old database and of the
+// new location while the old one is there to be moved, and empty strings otherwise
+func pendingLegacyDatabase(legacyPath, targetPath string) (string, string, error) {
+	if _, err := os.Stat(targetPath); err == nil {
+		return "", "", nil
+	}
+	if _, err := os.Stat(legacyPath); err != nil {
+		return "", "", nil
+	}
+
+	legacyAbs, err := filepath.Abs(legacyPath)
+	if err != nil {
+		return "", "", err
+	}
+	targetAbs, err := filepath.Abs(targetPath)
+	if err != nil {
+		return "", "", err
+	}
+	if legacyAbs == targetAbs {
+		return "", "", nil
+	}
+	return legacyAbs, targetAbs, nil
+}
+
+// keepLegacyDatabase is for commands that cannot ask, such as 'prompt' or those
+// writing JSON: an old database that was not moved yet stays in use, with a hint
+// on how to move it.
+func keepLegacyDatabase(legacyPath, targetPath string, out io.Writer) (string, error) {
+	legacyAbs, targetAbs, err := pendingLegacyDatabase(legacyPath, targetPath)
+	if err != nil || legacyAbs == "" {
+		return targetPath, err
+	}
+	fmt.Fprintf(out, "tomatillo: using %s, run a command in a terminal to move it to %s\n", legacyAbs, targetAbs)
+	return legacyPath, nil
+}
+
+// offerLegacyDatabaseMove asks once whether an old database in the current
+// directory should be moved to the new location. It only asks while nothing
+// exists at the new location, so answering no starts a fresh database there
+// and the question is not repeated.
+func offerLegacyDatabaseMove(legacyPath, targetPath string, in io.Reader, out io.Writer) error {
+	legacyAbs, targetAbs, err := pendingLegacyDatabase(legacyPath, targetPath)
+	if err != nil || legacyAbs == "" {
+		return err
+	}
+
+	fmt.Fprintf(out, "Found an existing database at %s.\nMove it to %s? [y/N] ", legacyAbs, targetAbs)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		fmt.Fprintf(out, "Leaving %s in place and starting a new database at %s.\n", legacyAbs, targetAbs)
+		return nil
+	}
+
+	if err := moveFile(legacyAbs, targetAbs); err != nil {
+		return fmt.Errorf("failed to move database: %v", err)
+	}
+	fmt.Fprintf(out, "Moved database to %s\n", targetAbs)
+	return nil
+}
+
+// moveFile renames a file, copying it when the rename crosses filesystems
+func moveFile(from, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(to)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(to)
+		return err
+	}
+	return os.Remove(from)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveDatabasePath(t *testing.T) {
+	env := map[string]string{"TOMATILLO_DB": "/tmp/from-env.db"}
+	getenv := func(key string) string { return env[key] }
+
+	path, err := resolveDatabasePath("/tmp/from-flag.db", getenv, false)
+	if err != nil || path != "/tmp/from-flag.db" {
+		t.Errorf("expected the --db flag to win, got %q (%v)", path, err)
+	}
+
+	path, err = resolveDatabasePath("", getenv, false)
+	if err != nil || path != "/tmp/from-env.db" {
+		t.Errorf("expected TOMATILLO_DB to be used, got %q (%v)", path, err)
+	}
+}
+
+func TestDefaultDatabasePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected string
+	}{
+		{"XDG data home", map[string]string{"XDG_DATA_HOME": "/data", "HOME": "/home/me"}, "/data/tomatillo/tomatillo.db"},
+		{"Relative XDG data home", map[string]string{"XDG_DATA_HOME": "data", "HOME": "/home/me"}, "/home/me/.local/share/tomatillo/tomatillo.db"},
+		{"Home fallback", map[string]string{"HOME": "/home/me"}, "/home/me/.local/share/tomatillo/tomatillo.db"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := defaultDatabasePath(func(key string) string { return tt.env[key] })
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if path != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, path)
+			}
+		})
+	}
+
+	if _, err := defaultDatabasePath(func(string) string { return "" }); err == nil {
+		t.Error("expected an error without a home directory, but got none")
+	}
+}
+
+func TestOfferLegacyDatabaseMove(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "tomatillo.db")
+	target := filepath.Join(dir, "data", "tomatillo.db")
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(legacy, []byte("sqlite"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// declining leaves the old database where it is
+	var out bytes.Buffer
+	if err := offerLegacyDatabaseMove(legacy, target, strings.NewReader("n\n"), &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(legacy); err != nil {
+		t.Errorf("expected the old database to stay in place: %v", err)
+	}
+
+	if err := offerLegacyDatabaseMove(legacy, target, strings.NewReader("y\n"), &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("expected the old database to be moved away, got %v", err)
+	}
+	content, err := os.ReadFile(target)
+	if err != nil || string(content) != "sqlite" {
+		t.Errorf("expected the database to be moved to %s, got %q (%v)", target, content, err)
+	}
+}
+
+func TestKeepLegacyDatabase(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "tomatillo.db")
+	target := filepath.Join(dir, "data", "tomatillo.db")
+
+	// nothing to move
+	var out bytes.Buffer
+	if path, err := keepLegacyDatabase(legacy, target, &out); err != nil || path != target || out.Len() != 0 {
+		t.Errorf("expected %s without a hint, got %s %q (%v)", target, path, out.String(), err)
+	}
+
+	if err := os.WriteFile(legacy, []byte("sqlite"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path, err := keepLegacyDatabase(legacy, target, &out)
+	if err != nil || path != legacy {
+		t.Errorf("expected the old database to stay in use, got %s (%v)", path, err)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 1 || !strings.Contains(out.String(), target) {
+		t.Errorf("expected a one line hint, got %q", out.String())
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Errorf("expected nothing at %s yet, got %v", target, err)
+	}
+}
@@ -1,13 +1,44 @@
 package main
 
 import (
+	"fmt"
+	"regexp"
+	"strconv"
 	"time"
 )
 
+// startOfDay returns midnight of the day t falls on
+func startOfDay(t time.Time) time.Time {
+    return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// weekStart is the first day of the week, set from the week_start setting
+var weekStart = time.Sunday
+
+// getWeek returns the first and last day of the week t falls on, at midnight
 func getWeek(mytime time.Time) (time.Time, time.Time) {
-    sunday := mytime.AddDate(0, 0, -int(mytime.Weekday()))
-    saturday := sunday.AddDate(0, 0, 6)
-    return sunday, saturday
+    day := startOfDay(mytime)
+    first := day.AddDate(0, 0, -((int(day.Weekday())-int(weekStart)+7)%7))
+    last := first.AddDate(0, 0, 6)
+    return first, last
+}
+
+// isoWeek names the ISO week of a week drawn by getWeek, such as 2026-W40. When
+// weeks start on another day than Monday it is the ISO week of their Monday.
+func isoWeek(first time.Time) string {
+    monday := first.AddDate(0, 0, (int(time.Monday)-int(first.Weekday())+7)%7)
+    year, week := monday.ISOWeek()
+    return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// weekLabel returns the ISO week of a period that is exactly one week as getWeek
+// draws it, and an empty string for any other period
+func weekLabel(start, end time.Time) string {
+    first, last := getWeek(start)
+    if !first.Equal(startOfDay(start)) || !last.Equal(startOfDay(end)) {
+        return ""
+    }
+    return isoWeek(first)
 }
 
 func getMonth(mytime time.Time) (time.Time, time.Time) {
@@ -22,6 +53,55 @@ func getMonth(mytime time.Time) (time.Time, time.Time) {
     return firstOfMonth, lastOfMonth
 }
 
+// getYear returns the first and last day of the year t falls in
+func getYear(mytime time.Time) (time.Time, time.Time) {
+    firstOfYear := time.Date(mytime.Year(), time.January, 1, 0, 0, 0, 0, mytime.Location())
+    return firstOfYear, firstOfYear.AddDate(1, 0, -1)
+}
+
+var isoWeekPattern = regexp.MustCompile(`^(\d{4})-W(\d{2})$`)
+
+// parseWeek reads an ISO week such as 2026-W40 and returns the week holding its
+// Monday, as getWeek draws weeks. With weeks starting on Monday that is exactly
+// the ISO week.
+func parseWeek(value string) (time.Time, time.Time, error) {
+    match := isoWeekPattern.FindStringSubmatch(value)
+    if match == nil {
+        return time.Time{}, time.Time{}, fmt.Errorf("invalid week %q, expected the format 2006-W01", value)
+    }
+    year, _ := strconv.Atoi(match[1])
+    week, _ := strconv.Atoi(match[2])
+
+    // the 4th of January is always in week 1
+    jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.Local)
+    monday := jan4.AddDate(0, 0, -(int(jan4.Weekday())+6)%7+7*(week-1))
+    if y, w := monday.ISOWeek(); y != year || w != week {
+        return time.Time{}, time.Time{}, fmt.Errorf("invalid week %q, %d has no week %d", value, year, week)
+    }
+    start, end := getWeek(monday)
+    return start, end, nil
+}
+
+// parseMonth reads a month such as 2026-09 and returns its first and last day
+func parseMonth(value string) (time.Time, time.Time, error) {
+    month, err := time.ParseInLocation("2006-01", value, time.Local)
+    if err != nil {
+        return time.Time{}, time.Time{}, fmt.Errorf("invalid month %q, expected the format 2006-01", value)
+    }
+    start, end := getMonth(month)
+    return start, end, nil
+}
+
+// parseYear reads a year such as 2025 and returns its first and last day
+func parseYear(value string) (time.Time, time.Time, error) {
+    year, err := time.ParseInLocation("2006", value, time.Local)
+    if err != nil {
+        return time.Time{}, time.Time{}, fmt.Errorf("invalid year %q, expected the format 2006", value)
+    }
+    start, end := getYear(year)
+    return start, end, nil
+}
+
 // Helper function to get the first three letters of the month
 func getMonthAbbreviation(month time.Month) string {
     // Get the full month name and return the first three letters
@@ -35,3 +115,13 @@ func generateEmojis(count int, emoji string) string {
     }
     return result
 }
+
+// truncate shortens text to at most length characters, ending in an ellipsis
+// when it had to cut
+func truncate(text string, length int) string {
+    runes := []rune(text)
+    if len(runes) <= length {
+        return text
+    }
+    return string(runes[:length-1]) + "…"
+}
@@ -38,20 +38,9 @@ func setupTestDB(t *testing.T) *sql.DB {
         t.Fatalf("Failed to create test database: %v", err)
     }
 
-    // Create the tasks table
-    createTableQuery := `
-    CREATE TABLE IF NOT EXISTS tasks (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        name TEXT NOT NULL,
-        estimate INTEGER NOT NULL,
-        actual INTEGER DEFAULT 0,
-        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-        done BOOLEAN DEFAULT 0
-    );`
-    _, err = db.Exec(createTableQuery)
-    if err != nil {
-        t.Fatalf("Failed to create tasks table: %v", err)
+    // Create the schema from the migrations
+    if _, err := migrateDatabase(db, false); err != nil {
+        t.Fatalf("Failed to create test schema: %v", err)
     }
 
     return db
@@ -79,7 +68,7 @@ func TestAddTask(t *testing.T) {
 
     for _, tt := range tests {
         t.Run(tt.name, func(t *testing.T) {
-            err := addTask(db, tt.name, tt.estimate)
+            err := addTask(db, tt.name, tt.estimate, time.Now())
             if tt.expectError {
                 if err == nil {
                     t.Errorf("Expected an error when adding a task with an empty name, but got none")
@@ -119,7 +108,7 @@ func TestDeleteTask(t *testing.T) {
     }
 
     for _, task := range tasks {
-        err := addTask(db, task.name, task.estimate)
+        err := addTask(db, task.name, task.estimate, time.Now())
         if err != nil {
             t.Fatalf("Failed to add task: %v", err)
         }
@@ -235,3 +224,116 @@ func TestGetMonth(t *testing.T) {
     }
 }
 
+
+func TestGetWeekAcrossYears(t *testing.T) {
+    tests := []struct {
+        date     time.Time
+        sunday   string
+        saturday string
+    }{
+        {time.Date(2026, time.January, 1, 23, 30, 0, 0, time.Local), "2025-12-28", "2026-01-03"},
+        {time.Date(2025, time.December, 31, 8, 0, 0, 0, time.Local), "2025-12-28", "2026-01-03"},
+        {time.Date(2024, time.December, 29, 0, 0, 0, 0, time.Local), "2024-12-29", "2025-01-04"},
+        {time.Date(2021, time.January, 2, 12, 0, 0, 0, time.Local), "2020-12-27", "2021-01-02"},
+    }
+
+    for _, tt := range tests {
+        sunday, saturday := getWeek(tt.date)
+        if formatDate(sunday) != tt.sunday || formatDate(saturday) != tt.saturday {
+            t.Errorf("getWeek(%s) = %s to %s; want %s to %s", formatDate(tt.date), formatDate(sunday), formatDate(saturday), tt.sunday, tt.saturday)
+        }
+        if !sunday.Equal(startOfDay(sunday)) {
+            t.Errorf("expected getWeek(%s) to start at midnight, got %v", formatDate(tt.date), sunday)
+        }
+    }
+}
+
+func TestParseWeek(t *testing.T) {
+    tests := []struct {
+        week     string
+        sunday   string
+        saturday string
+    }{
+        {"2026-W40", "2026-09-27", "2026-10-03"},
+        {"2026-W01", "2025-12-28", "2026-01-03"}, // week 1 starts in the year before
+        {"2020-W53", "2020-12-27", "2021-01-02"}, // a year with 53 weeks
+        {"2025-W01", "2024-12-29", "2025-01-04"},
+    }
+
+    for _, tt := range tests {
+        sunday, saturday, err := parseWeek(tt.week)
+        if err != nil {
+            t.Errorf("parseWeek(%q) failed: %v", tt.week, err)
+            continue
+        }
+        if formatDate(sunday) != tt.sunday || formatDate(saturday) != tt.saturday {
+            t.Errorf("parseWeek(%q) = %s to %s; want %s to %s", tt.week, formatDate(sunday), formatDate(saturday), tt.sunday, tt.saturday)
+        }
+    }
+
+    for _, week := range []string{"2021-W53", "2026-W00", "2026-40", "2026-W4"} {
+        if _, _, err := parseWeek(week); err == nil {
+            t.Errorf("expected parseWeek(%q) to fail", week)
+        }
+    }
+}
+
+func TestParseMonthAndYear(t *testing.T) {
+    first, last, err := parseMonth("2024-02")
+    if err != nil || formatDate(first) != "2024-02-01" || formatDate(last) != "2024-02-29" {
+        t.Errorf("expected February 2024 to have 29 days, got %s to %s (%v)", formatDate(first), formatDate(last), err)
+    }
+    first, last, err = parseMonth("2025-12")
+    if err != nil || formatDate(first) != "2025-12-01" || formatDate(last) != "2025-12-31" {
+        t.Errorf("unexpected December 2025: %s to %s (%v)", formatDate(first), formatDate(last), err)
+    }
+    if _, _, err := parseMonth("2025-13"); err == nil {
+        t.Errorf("expected month 13 to be rejected")
+    }
+
+    first, last, err = parseYear("2025")
+    if err != nil || formatDate(first) != "2025-01-01" || formatDate(last) != "2025-12-31" {
+        t.Errorf("unexpected year 2025: %s to %s (%v)", formatDate(first), formatDate(last), err)
+    }
+    if _, _, err := parseYear("25"); err == nil {
+        t.Errorf("expected a two digit year to be rejected")
+    }
+}
+
+func TestWeekStart(t *testing.T) {
+    defer func() { weekStart = time.Sunday }()
+
+    tests := []struct {
+        start time.Weekday
+        date  time.Time
+        first string
+        last  string
+        week  string
+    }{
+        {time.Sunday, time.Date(2026, time.October, 3, 0, 0, 0, 0, time.Local), "2026-09-27", "2026-10-03", "2026-W40"},
+        {time.Monday, time.Date(2026, time.October, 4, 0, 0, 0, 0, time.Local), "2026-09-28", "2026-10-04", "2026-W40"},
+        {time.Monday, time.Date(2027, time.January, 2, 0, 0, 0, 0, time.Local), "2026-12-28", "2027-01-03", "2026-W53"},
+        {time.Saturday, time.Date(2026, time.October, 2, 0, 0, 0, 0, time.Local), "2026-09-26", "2026-10-02", "2026-W40"},
+    }
+
+    for _, tt := range tests {
+        weekStart = tt.start
+        first, last := getWeek(tt.date)
+        if formatDate(first) != tt.first || formatDate(last) != tt.last {
+            t.Errorf("getWeek(%s) starting %v = %s to %s; want %s to %s", formatDate(tt.date), tt.start, formatDate(first), formatDate(last), tt.first, tt.last)
+        }
+        if week := weekLabel(first, last); week != tt.week {
+            t.Errorf("weekLabel(%s, %s) = %q; want %q", tt.first, tt.last, week, tt.week)
+        }
+        if week := weekLabel(first, last.AddDate(0, 0, 1)); week != "" {
+            t.Errorf("expected 8 days not to be a week, got %q", week)
+        }
+    }
+
+    // with weeks starting on Monday --week is exactly the ISO week
+    weekStart = time.Monday
+    first, last, err := parseWeek("2026-W01")
+    if err != nil || formatDate(first) != "2025-12-29" || formatDate(last) != "2026-01-04" {
+        t.Errorf("expected 2026-W01 to run from 2025-12-29 to 2026-01-04, got %s to %s (%v)", formatDate(first), formatDate(last), err)
+    }
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loadColumns are the columns a load file may have. Files without a header row
+// are read in this order, so the old "name,estimate" files keep working.
+var loadColumns = []string{"name", "estimate", "project", "tags", "due", "notes"}
+
+// loadColumnAliases maps other spellings of a header to its column
+var loadColumnAliases = map[string]string{
+	"task":     "name",
+	"tag":      "tags",
+	"due_date": "due",
+	"due date": "due",
+	"note":     "notes",
+}
+
+// loadRow is a task read from a load file along with the line it came from
+type loadRow struct {
+	line int
+	task Task
+}
+
+// loadError explains why a line of a load file was rejected
+type loadError struct {
+	line   int
+	reason string
+}
+
+func (e loadError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.reason)
+}
+
+// parseTaskCSV reads tasks from a CSV load file. Blank lines and lines starting
+// with # are skipped. When the first row starts with a "name" column it is taken
+// as the header, otherwise the columns follow loadColumns. Rows that cannot be
+// read are returned as loadErrors so the whole file can be checked in one go.
+func parseTaskCSV(r io.Reader) ([]loadRow, []loadError, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rows []loadRow
+	var rejected []loadError
+	var columns []string
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, fmt.Errorf("error reading file: %v", err)
+			}
+			rejected = append(rejected, loadError{parseErr.StartLine, parseErr.Err.Error()})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		if columns == nil {
+			header, isHeader, err := parseLoadHeader(record)
+			if err != nil {
+				return nil, nil, loadError{line, err.Error()}
+			}
+			columns = header
+			if isHeader {
+				continue
+			}
+		}
+
+		task, err := parseLoadRecord(columns, record)
+		if err != nil {
+			rejected = append(rejected, loadError{line, err.Error()})
+			continue
+		}
+		rows = append(rows, loadRow{line, task})
+	}
+
+	return rows, rejected, nil
+}
+
+// parseLoadHeader works out the columns of a load file from its first row and
+// reports whether that row is a header that should not be loaded as a task
+func parseLoadHeader(record []string) ([]string, bool, error) {
+	if loadColumnName(record[0]) != "name" {
+		return loadColumns, false, nil
+	}
+
+	columns := make([]string, len(record))
+	seen := make(map[string]bool)
+	for i, field := range record {
+		column := loadColumnName(field)
+		known := false
+		for _, c := range loadColumns {
+			known = known || c == column
+		}
+		if !known {
+			return nil, false, fmt.Errorf("unknown column %q, expected %s", strings.TrimSpace(field), strings.Join(loadColumns, ", "))
+		}
+		if seen[column] {
+			return nil, false, fmt.Errorf("column %q appears more than once", column)
+		}
+		seen[column] = true
+		columns[i] = column
+	}
+	return columns, true, nil
+}
+
+// loadColumnName normalizes a header so "Due Date" and "due" are the same column
+func loadColumnName(field string) string {
+	name := strings.ToLower(strings.TrimSpace(field))
+	if alias, ok := loadColumnAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// parseLoadRecord turns one row of a load file into a task
+func parseLoadRecord(columns []string, record []string) (Task, error) {
+	if len(record) > len(columns) {
+		return Task{}, fmt.Errorf("expected at most %d fields, got %d", len(columns), len(record))
+	}
+
+	task := Task{Estimate: 1}
+	for i, field := range record {
+		field = strings.TrimSpace(field)
+		switch columns[i] {
+		case "name":
+			task.Name = field
+		case "estimate":
+			if field == "" {
+				continue
+			}
+			estimate, err := strconv.Atoi(field)
+			if err != nil || estimate < 0 {
+				return Task{}, fmt.Errorf("invalid estimate %q", field)
+			}
+			task.Estimate = estimate
+		case "project":
+			task.Project = field
+		case "tags":
+			for _, tag := range strings.FieldsFunc(field, func(r rune) bool { return r == ';' || r == ',' || r == ' ' }) {
+				task.Tags = append(task.Tags, normalizeTag(tag))
+			}
+		case "due":
+			if field == "" {
+				continue
+			}
+			if _, err := time.Parse("2006-01-02", field); err != nil {
+				return Task{}, fmt.Errorf("invalid due date %q, expected YYYY-MM-DD", field)
+			}
+			task.DueDate = field
+		case "notes":
+			task.Notes = field
+		}
+	}
+
+	if task.Name == "" {
+		return Task{}, fmt.Errorf("task name is missing")
+	}
+	return task, nil
+}
+
+// markdownItem matches a checklist item such as "- [ ] Write tests (3)"
+var markdownItem = regexp.MustCompile(`^\s*[-*+]\s+\[([ xX])\]\s+(.*)$`)
+
+// estimateSuffix matches the "(3)" estimate at the end of a checklist item
+var estimateSuffix = regexp.MustCompile(`^\((\d+)\)$`)
+
+// todoTxtDate matches the completion and creation dates of a todo.txt line
+var todoTxtDate = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// todoTxtPriority matches the "(A)" priority of a todo.txt line
+var todoTxtPriority = regexp.MustCompile(`^\([A-Z]\)$`)
+
+// parseMarkdownTasks reads the checklist items of a Markdown file. Everything
+// else, headings and prose included, is skipped. Checked items are loaded as done.
+func parseMarkdownTasks(r io.Reader) ([]loadRow, []loadError, error) {
+	var rows []loadRow
+	var rejected []loadError
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		match := markdownItem.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+
+		words := strings.Fields(match[2])
+		estimate := ""
+		if n := len(words); n > 0 {
+			if suffix := estimateSuffix.FindStringSubmatch(words[n-1]); suffix != nil {
+				estimate = suffix[1]
+				words = words[:n-1]
+			}
+		}
+
+		task, err := parseTaskWords(words)
+		if err != nil {
+			rejected = append(rejected, loadError{line, err.Error()})
+			continue
+		}
+		if estimate != "" {
+			task.Estimate, _ = strconv.Atoi(estimate)
+		}
+		task.Done = match[1] != " "
+		rows = append(rows, loadRow{line, task})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error reading file: %v", err)
+	}
+
+	return rows, rejected, nil
+}
+
+// parseTodoTxtTasks reads a todo.txt file. Lines starting with "x " are loaded as
+// done, priorities and the completion and creation dates are skipped.
+func parseTodoTxtTasks(r io.Reader) ([]loadRow, []loadError, error) {
+	var rows []loadRow
+	var rejected []loadError
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		words := strings.Fields(scanner.Text())
+		if len(words) == 0 || strings.HasPrefix(words[0], "#") {
+			continue
+		}
+
+		done := words[0] == "x"
+		if done {
+			words = words[1:]
+		}
+		if len(words) > 0 && !done && todoTxtPriority.MatchString(words[0]) {
+			words = words[1:]
+		}
+		// a done task may have a completion date followed by a creation date
+		for i := 0; i < 2 && len(words) > 0 && todoTxtDate.MatchString(words[0]); i++ {
+			words = words[1:]
+		}
+
+		task, err := parseTaskWords(words)
+		if err != nil {
+			rejected = append(rejected, loadError{line, err.Error()})
+			continue
+		}
+		task.Done = done
+		rows = append(rows, loadRow{line, task})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error reading file: %v", err)
+	}
+
+	return rows, rejected, nil
+}
+
+// parseTaskWords builds a task from the words of a checklist item or todo.txt
+// line: +project sets the project, @context adds a tag, est:3 sets the estimate
+// and due:2006-01-02 the due date. The remaining words are the name.
+func parseTaskWords(words []string) (Task, error) {
+	task := Task{Estimate: 1}
+	var name []string
+
+	for _, word := range words {
+		switch {
+		case len(word) > 1 && strings.HasPrefix(word, "+"):
+			if task.Project != "" && task.Project != word[1:] {
+				return Task{}, fmt.Errorf("more than one project: +%s and %s", task.Project, word)
+			}
+			task.Project = word[1:]
+		case len(word) > 1 && strings.HasPrefix(word, "@"):
+			task.Tags = append(task.Tags, normalizeTag(word[1:]))
+		case strings.HasPrefix(word, "est:"):
+			estimate, err := strconv.Atoi(word[len("est:"):])
+			if err != nil || estimate < 0 {
+				return Task{}, fmt.Errorf("invalid estimate %q", word)
+			}
+			task.Estimate = estimate
+		case strings.HasPrefix(word, "due:"):
+			due := word[len("due:"):]
+			if _, err := time.Parse("2006-01-02", due); err != nil {
+				return Task{}, fmt.Errorf("invalid due date %q, expected YYYY-MM-DD", due)
+			}
+			task.DueDate = due
+		default:
+			name = append(name, word)
+		}
+	}
+
+	task.Name = strings.Join(name, " ")
+	if task.Name == "" {
+		return Task{}, fmt.Errorf("task name is missing")
+	}
+	return task, nil
+}
+
+// loadParsers reads each format accepted by 'load --from'
+var loadParsers = map[string]func(io.Reader) ([]loadRow, []loadError, error){
+	"csv":      parseTaskCSV,
+	"markdown": parseMarkdownTasks,
+	"todotxt":  parseTodoTxtTasks,
+}
+
+// checkLoadProjects rejects rows whose project is archived, or does not exist
+// unless createProjects is set. Rows without a project are put in defaultProject.
+func checkLoadProjects(db *sql.DB, rows []loadRow, defaultProject string, createProjects bool) ([]loadRow, []loadError) {
+	projectErrors := make(map[string]error)
+	var valid []loadRow
+	var rejected []loadError
+
+	for _, row := range rows {
+		if row.task.Project == "" {
+			row.task.Project = defaultProject
+		}
+		if row.task.Project != "" {
+			err, checked := projectErrors[row.task.Project]
+			if !checked {
+				project, lookupErr := getProjectByName(db, row.task.Project)
+				if errors.Is(lookupErr, errNoProject) && createProjects {
+					err = nil
+				} else if lookupErr != nil {
+					err = lookupErr
+				} else if project.Archived {
+					err = fmt.Errorf("project %s is archived", row.task.Project)
+				}
+				projectErrors[row.task.Project] = err
+			}
+			if err != nil {
+				rejected = append(rejected, loadError{row.line, err.Error()})
+				continue
+			}
+		}
+		valid = append(valid, row)
+	}
+	return valid, rejected
+}
+
+// loadTasks adds the rows at now in a single transaction and returns them with
+// their new IDs. With createProjects set, missing projects are added in the same transaction.
+// A row that fails rolls the whole load back, unless skipInvalid is set, then
+// only that row is undone and reported with the rejected lines.
+func loadTasks(db *sql.DB, rows []loadRow, skipInvalid, createProjects bool, now time.Time) ([]loadRow, []loadError, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin load: %v", err)
+	}
+	defer tx.Rollback()
+
+	var loaded []loadRow
+	var rejected []loadError
+	for _, row := range rows {
+		if _, err := tx.Exec(`SAVEPOINT load_row`); err != nil {
+			return nil, nil, err
+		}
+		var id int
+		var err error
+		if createProjects && row.task.Project != "" {
+			err = ensureProject(tx, row.task.Project, now)
+		}
+		if err == nil {
+			id, err = createTask(tx, row.task, now)
+		}
+		if err != nil {
+			if !skipInvalid {
+				return nil, nil, loadError{row.line, err.Error()}
+			}
+			if _, err := tx.Exec(`ROLLBACK TO load_row`); err != nil {
+				return nil, nil, err
+			}
+			rejected = append(rejected, loadError{row.line, err.Error()})
+		} else {
+			row.task.ID = id
+			loaded = append(loaded, row)
+		}
+		if _, err := tx.Exec(`RELEASE load_row`); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit load: %v", err)
+	}
+	return loaded, rejected, nil
+}
+
+// formatLoadErrors lists rejected lines in order, one per line
+func formatLoadErrors(rejected []loadError) string {
+	sort.SliceStable(rejected, func(i, j int) bool { return rejected[i].line < rejected[j].line })
+	lines := make([]string, len(rejected))
+	for i, e := range rejected {
+		lines[i] = "  " + e.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Helper function to handle the 'load' command. The file is read as CSV unless
+// --from says otherwise. The whole file is checked first and loaded in one
+// transaction, so either every task is added or none is. With --skip-invalid the
+// good rows are added and the rejected ones are listed.
+func handleLoadTasksCommand(db *sql.DB, args []string) error {
+	loadTasksFlag := flag.NewFlagSet("load", flag.ExitOnError)
+	filePath := loadTasksFlag.String("file", "", "Path to the CSV file containing tasks and estimates")
+	// add a short version of the flag
+	loadTasksFlag.StringVar(filePath, "f", "", "Path to the CSV file containing tasks and estimates")
+	project := loadTasksFlag.String("project", "", "Project to add tasks without a project column to (or use -p)")
+	loadTasksFlag.StringVar(project, "p", "", "Project to add the loaded tasks to (short version)")
+	dryRun := loadTasksFlag.Bool("dry-run", false, "Check the file without adding any tasks")
+	from := loadTasksFlag.String("from", "csv", "Format of the file: csv, markdown or todotxt")
+	skipInvalid := loadTasksFlag.Bool("skip-invalid", false, "Load the valid rows and report the rejected ones instead of loading nothing")
+	createProjects := loadTasksFlag.Bool("create-projects", false, "Add the projects of the file that do not exist yet, such as todo.txt +project words")
+
+	loadTasksFlag.Parse(args)
+
+	if *filePath == "" {
+		return fmt.Errorf("file path is required")
+	}
+	parse, ok := loadParsers[*from]
+	if !ok {
+		return fmt.Errorf("invalid format %q, expected csv, markdown or todotxt", *from)
+	}
+	if *project != "" {
+		if _, err := getProjectByName(db, *project); err != nil && !(errors.Is(err, errNoProject) && *createProjects) {
+			return err
+		}
+	}
+
+	file, err := os.Open(*filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	rows, rejected, err := parse(file)
+	if err != nil {
+		return err
+	}
+	rows, unknownProjects := checkLoadProjects(db, rows, *project, *createProjects)
+	rejected = append(rejected, unknownProjects...)
+
+	if len(rejected) > 0 && !*skipInvalid {
+		return fmt.Errorf("%d invalid lines, no tasks were loaded (use --skip-invalid to load the others):\n%s",
+			len(rejected), formatLoadErrors(rejected))
+	}
+
+	if *dryRun {
+		fmt.Printf("%d tasks are valid, nothing was loaded (dry run).\n", len(rows))
+		if len(rejected) > 0 {
+			fmt.Printf("%d invalid lines would be skipped:\n%s\n", len(rejected), formatLoadErrors(rejected))
+		}
+		return nil
+	}
+
+	loaded, failed, err := loadTasks(db, rows, *skipInvalid, *createProjects, clock.Now())
+	if err != nil {
+		return fmt.Errorf("no tasks were loaded: %v", err)
+	}
+	rejected = append(rejected, failed...)
+
+	for _, row := range loaded {
+		printAddedTask(row.task, row.task.ID)
+	}
+	fmt.Printf("Loaded %d tasks from file.\n", len(loaded))
+	if len(rejected) > 0 {
+		fmt.Printf("Skipped %d invalid lines:\n%s\n", len(rejected), formatLoadErrors(rejected))
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeLoadFile writes the content of a load file to a temporary directory
+func writeLoadFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "tasks.csv")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write load file: %v", err)
+	}
+	return path
+}
+
+func countTasks(t *testing.T) int {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM tasks`).Scan(&count); err != nil {
+		t.Fatalf("failed to count tasks: %v", err)
+	}
+	return count
+}
+
+func TestParseTaskCSV(t *testing.T) {
+	content := `# sprint 12
+name,estimate,project,tags,due date,notes
+
+"Fix login, again",2,website,bug;auth,2024-10-01,
+,3
+Write release notes,,,docs,,"Mention the ""load"" changes"
+Broken,two
+Late,1,,,tomorrow
+`
+	rows, rejected, err := parseTaskCSV(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 valid rows, got %d: %+v", len(rows), rows)
+	}
+	first := rows[0].task
+	if rows[0].line != 4 || first.Name != "Fix login, again" || first.Estimate != 2 || first.Project != "website" ||
+		strings.Join(first.Tags, ",") != "bug,auth" || first.DueDate != "2024-10-01" {
+		t.Errorf("unexpected first row: line %d %+v", rows[0].line, first)
+	}
+	second := rows[1].task
+	if second.Estimate != 1 || second.Notes != `Mention the "load" changes` {
+		t.Errorf("unexpected second row: %+v", second)
+	}
+
+	var lines []int
+	for _, e := range rejected {
+		lines = append(lines, e.line)
+	}
+	if len(lines) != 3 || lines[0] != 5 || lines[1] != 7 || lines[2] != 8 {
+		t.Errorf("expected lines 5, 7 and 8 to be rejected, got %v", rejected)
+	}
+}
+
+func TestParseTaskCSVUnknownColumn(t *testing.T) {
+	_, _, err := parseTaskCSV(strings.NewReader("name,priority\nTask,high\n"))
+	if err == nil || !strings.Contains(err.Error(), "priority") {
+		t.Errorf("expected an error about the unknown column, got %v", err)
+	}
+}
+
+func TestHandleLoadTasksCommandRejectsWholeFile(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	path := writeLoadFile(t, "Task1,3\nTask2,lots\nTask3,1,Initech\n")
+	err := handleLoadTasksCommand(db, []string{"--file", path})
+	if err == nil {
+		t.Fatal("expected an error for the invalid lines, but got none")
+	}
+	if !strings.Contains(err.Error(), "line 2") || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("expected every invalid line to be reported, got %v", err)
+	}
+	if count := countTasks(t); count != 0 {
+		t.Errorf("expected no tasks to be loaded, got %d", count)
+	}
+}
+
+func TestHandleLoadTasksCommandDryRun(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	path := writeLoadFile(t, "name,estimate\nTask1,3\nTask2,2\n")
+	if err := handleLoadTasksCommand(db, []string{"--file", path, "--dry-run"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count := countTasks(t); count != 0 {
+		t.Errorf("expected a dry run to load nothing, got %d tasks", count)
+	}
+
+	if err := handleLoadTasksCommand(db, []string{"--file", path}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count := countTasks(t); count != 2 {
+		t.Errorf("expected 2 tasks to be loaded, got %d", count)
+	}
+}
+
+func TestLoadTasksRollsBack(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	rows := []loadRow{
+		{1, Task{Name: "Task1", Estimate: 1, Tags: []string{"docs"}}},
+		{2, Task{Name: "Task2", Estimate: 1, Project: "Initech"}},
+		{3, Task{Name: "Task3", Estimate: 1}},
+	}
+
+	if _, _, err := loadTasks(db, rows, false, false, time.Now()); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected the load to fail on line 2, got %v", err)
+	}
+	if count := countTasks(t); count != 0 {
+		t.Errorf("expected the load to be rolled back, got %d tasks", count)
+	}
+
+	loaded, rejected, err := loadTasks(db, rows, true, false, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(loaded) != 2 || len(rejected) != 1 || rejected[0].line != 2 {
+		t.Errorf("expected 2 loaded rows and line 2 rejected, got %v and %v", loaded, rejected)
+	}
+	if count := countTasks(t); count != 2 {
+		t.Errorf("expected 2 tasks to be loaded, got %d", count)
+	}
+}
+
+func TestHandleLoadTasksCommandSkipInvalid(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	path := writeLoadFile(t, "Task1,3\nTask2,lots\nTask3,1,Initech\nTask4,2\n")
+	if err := handleLoadTasksCommand(db, []string{"--file", path, "--skip-invalid"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count := countTasks(t); count != 2 {
+		t.Errorf("expected the 2 valid tasks to be loaded, got %d", count)
+	}
+}
+
+func TestParseMarkdownTasks(t *testing.T) {
+	content := `# Sprint 12
+
+Some notes about the sprint.
+
+- [ ] Write tests (3)
+- [x] Fix login +website @bug
+  * [ ] Review the docs est:2 due:2024-10-01
+- [ ] (2)
+- Not a checklist item
+`
+	rows, rejected, err := parseMarkdownTasks(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 tasks, got %d: %+v", len(rows), rows)
+	}
+
+	if task := rows[0].task; rows[0].line != 5 || task.Name != "Write tests" || task.Estimate != 3 || task.Done {
+		t.Errorf("unexpected first task: line %d %+v", rows[0].line, task)
+	}
+	if task := rows[1].task; task.Name != "Fix login" || task.Estimate != 1 || !task.Done || task.Project != "website" ||
+		strings.Join(task.Tags, ",") != "bug" {
+		t.Errorf("unexpected second task: %+v", task)
+	}
+	if task := rows[2].task; task.Name != "Review the docs" || task.Estimate != 2 || task.DueDate != "2024-10-01" {
+		t.Errorf("unexpected third task: %+v", task)
+	}
+	if len(rejected) != 1 || rejected[0].line != 8 {
+		t.Errorf("expected the item without a name on line 8 to be rejected, got %v", rejected)
+	}
+}
+
+func TestParseTodoTxtTasks(t *testing.T) {
+	content := `(A) 2024-09-20 Call the bank +personal @phone est:2
+x 2024-09-21 2024-09-19 Renew passport +personal
+Write release notes due:2024-10-01
+
+Plan trip +personal +work
+`
+	rows, rejected, err := parseTodoTxtTasks(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 tasks, got %d: %+v", len(rows), rows)
+	}
+
+	if task := rows[0].task; task.Name != "Call the bank" || task.Estimate != 2 || task.Project != "personal" ||
+		strings.Join(task.Tags, ",") != "phone" || task.Done {
+		t.Errorf("unexpected first task: %+v", task)
+	}
+	if task := rows[1].task; task.Name != "Renew passport" || !task.Done {
+		t.Errorf("unexpected second task: %+v", task)
+	}
+	if task := rows[2].task; rows[2].line != 3 || task.Name != "Write release notes" || task.DueDate != "2024-10-01" {
+		t.Errorf("unexpected third task: line %d %+v", rows[2].line, task)
+	}
+	if len(rejected) != 1 || rejected[0].line != 5 {
+		t.Errorf("expected the task with two projects on line 5 to be rejected, got %v", rejected)
+	}
+}
+
+func TestHandleLoadTasksCommandMarkdown(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	path := writeLoadFile(t, "- [ ] Write tests (3)\n- [x] Fix login\n")
+	if err := handleLoadTasksCommand(db, []string{"--file", path, "--from", "markdown"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var done int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM tasks WHERE done = 1`).Scan(&done); err != nil {
+		t.Fatalf("failed to query database: %v", err)
+	}
+	if count := countTasks(t); count != 2 || done != 1 {
+		t.Errorf("expected 2 tasks with 1 done, got %d with %d done", count, done)
+	}
+
+	if err := handleLoadTasksCommand(db, []string{"--file", path, "--from", "org"}); err == nil {
+		t.Error("expected an error for an unknown format, but got none")
+	}
+}
+
+func TestHandleLoadTasksCommandCreateProjects(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	if _, err := addProject(db, "archive", time.Now()); err != nil {
+		t.Fatalf("failed to add project: %v", err)
+	}
+	if err := archiveProject(db, "archive"); err != nil {
+		t.Fatalf("failed to archive project: %v", err)
+	}
+
+	path := writeLoadFile(t, "Call the bank +personal\nPlan trip +personal\nShip it +work\nOld stuff +archive\n")
+	if err := handleLoadTasksCommand(db, []string{"--file", path, "--from", "todotxt"}); err == nil {
+		t.Fatal("expected unknown projects to be rejected without --create-projects")
+	}
+	if count := countTasks(t); count != 0 {
+		t.Errorf("expected nothing to be loaded, got %d tasks", count)
+	}
+
+	err := handleLoadTasksCommand(db, []string{"--file", path, "--from", "todotxt", "--create-projects", "--skip-invalid"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count := countTasks(t); count != 3 {
+		t.Errorf("expected the 3 tasks outside the archived project to be loaded, got %d", count)
+	}
+	projects, err := getProjects(db, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(projects) != 2 || projects[0].Name != "personal" || projects[1].Name != "work" {
+		t.Errorf("expected the personal and work projects to be added, got %+v", projects)
+	}
+}
@@ -1,13 +1,11 @@
 package main
 
 import (
-	"bufio"
 	"database/sql"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -15,62 +13,206 @@ import (
 var db *sql.DB
 
 func main() {
-	db = initializeDatabase("./tomatillo.db")
+	globals, args, err := parseGlobalFlags(os.Args[1:])
+	if err != nil {
+		os.Exit(2)
+	}
+
+	outputFormat = globals.format
+
+	// only ask about moving an old database when someone is there to answer
+	interactive := isTerminal(os.Stdin) && outputFormat == "text" && (len(args) == 0 || args[0] != "prompt")
+	dbPath, err := resolveDatabasePath(globals.dbPath, os.Getenv, interactive)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// 'db migrate' applies migrations itself, so it can show them before they run
+	if len(args) >= 2 && args[0] == "db" && args[1] == "migrate" {
+		db = openDatabase(dbPath)
+	} else {
+		db = initializeDatabase(dbPath)
+		if err := loadWeekStart(db); err != nil {
+			log.Fatal(err)
+		}
+		if err := loadTimezone(db); err != nil {
+			log.Fatal(err)
+		}
+	}
 	defer db.Close()
 
-	if len(os.Args) < 2 {
-		fmt.Println("expected 'add', 'activate', 'simple', 'list', 'update', 'done', 'edit', 'delete', 'load', or 'report' subcommands")
+	// --now is read in the configured time zone, so only once it is loaded
+	if globals.now != "" {
+		now, err := parseNow(globals.now)
+		if err != nil {
+			log.Fatal(err)
+		}
+		clock = shiftedClock(time.Until(now))
+	}
+
+	if len(args) < 1 {
+		fmt.Println("expected 'add', 'activate', 'start', 'status', 'resume', 'stop', 'prompt', 'serve', 'dashboard', 'interrupt', 'break', 'ui', 'config', 'db', 'project', 'export', 'import', 'simple', 'list', 'update', 'done', 'edit', 'delete', 'load', or 'report' subcommands")
 		os.Exit(1)
 	}
 
 	// Subcommand handling
-	command := os.Args[1]
+	command := args[0]
 	switch command {
 	case "add":
-		handleAddCommand(db, os.Args[2:])
+		if err := handleAddCommand(db, args[1:]); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
 	case "list":
-		handleListCommand(os.Args[2:])
+		handleListCommand(args[1:])
 	case "update":
-		handleUpdateCommand(os.Args[2:])
+		handleUpdateCommand(args[1:])
 	case "done":
-		handleDoneCommand(os.Args[2:])
+		handleDoneCommand(args[1:])
 	case "edit":
-		handleEditCommand(os.Args[2:])
+		handleEditCommand(args[1:])
 	case "report":
-		handleReportCommand(os.Args[2:])
+		handleReportCommand(args[1:])
 	case "delete":
-		handleDeleteCommand(os.Args[2:])
+		handleDeleteCommand(args[1:])
 	case "activate":
-		handleActivateCommand(os.Args[2:])
+		handleActivateCommand(args[1:])
+	case "start":
+		handleStartCommand(args[1:])
+	case "status":
+		handleStatusCommand(args[1:])
+	case "resume":
+		handleResumeCommand(args[1:])
+	case "stop":
+		handleStopCommand(args[1:])
+	case "prompt":
+		handlePromptCommand(args[1:])
+	case "serve":
+		handleServeCommand(args[1:])
+	case "dashboard":
+		handleDashboardCommand(args[1:])
+	case "interrupt":
+		handleInterruptCommand(args[1:])
+	case "break":
+		handleBreakCommand(args[1:])
+	case "ui":
+		handleUICommand(args[1:])
+	case "config":
+		handleConfigCommand(args[1:])
+	case "db":
+		handleDbCommand(args[1:])
+	case "project":
+		handleProjectCommand(args[1:])
+	case "export":
+		handleExportCommand(args[1:])
+	case "import":
+		handleImportCommand(args[1:])
 	case "backfill":
-		handleBackfillCommand(os.Args[2:])
+		handleBackfillCommand(args[1:])
 	case "load":
-		handleLoadTasksCommand(db, os.Args[2:])
+		if err := handleLoadTasksCommand(db, args[1:]); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
 	case "today":
 		// use the handle report command with the --type flag set to today
-		os.Args = append(os.Args, "--type", "today")
-		handleReportCommand(os.Args[2:])
+		args = append(args, "--type", "today")
+		handleReportCommand(args[1:])
 	case "version":
 		fmt.Println("tomatillo v0.1")
 	case "help":
 		handleHelpCommand()
 	default:
-		fmt.Println("expected 'add', 'activate', 'today', 'list', 'update', 'done', 'edit', 'delete', 'load', version', or 'report' subcommands")
+		fmt.Println("expected 'add', 'activate', 'start', 'status', 'resume', 'stop', 'prompt', 'serve', 'dashboard', 'interrupt', 'break', 'ui', 'config', 'db', 'project', 'export', 'import', 'today', 'list', 'update', 'done', 'edit', 'delete', 'load', version', or 'report' subcommands")
 		os.Exit(1)
 	}
 }
 
+// globalOptions holds the flags given before the subcommand
+type globalOptions struct {
+	dbPath string
+	format string
+	now    string
+}
+
+// parseGlobalFlags reads the global flags and returns the subcommand with its arguments
+func parseGlobalFlags(args []string) (globalOptions, []string, error) {
+	var globals globalOptions
+	globalFlag := flag.NewFlagSet("tomatillo", flag.ContinueOnError)
+	globalFlag.StringVar(&globals.dbPath, "db", "", "Path to the database file (or set TOMATILLO_DB)")
+	globalFlag.StringVar(&globals.format, "format", "text", "Output format of lists and reports: 'text', 'json' or 'csv'")
+	// --now is left out of the usage: it is meant for reproducing reports and tests
+	globalFlag.StringVar(&globals.now, "now", "", "Run a command that only reads as if it were this date or time")
+	globalFlag.Usage = func() {
+		fmt.Fprintln(globalFlag.Output(), "Usage: tomatillo [--db path] [--format text|json|csv] [command] [arguments]")
+		globalFlag.VisitAll(func(f *flag.Flag) {
+			if f.Name != "now" {
+				fmt.Fprintf(globalFlag.Output(), "  -%s\n    \t%s\n", f.Name, f.Usage)
+			}
+		})
+	}
+
+	if err := globalFlag.Parse(args); err != nil {
+		return globals, nil, err
+	}
+	if err := validateOutputFormat(globals.format); err != nil {
+		fmt.Fprintln(globalFlag.Output(), err)
+		return globals, nil, err
+	}
+	if globals.now != "" {
+		if _, err := parseNow(globals.now); err != nil {
+			fmt.Fprintln(globalFlag.Output(), err)
+			return globals, nil, err
+		}
+		// a task or pomodoro stamped with a made up time would corrupt the history
+		if command := globalFlag.Arg(0); command != "" && !readOnlyCommands[command] {
+			err := fmt.Errorf("--now only works with commands that do not change the database, not '%s'", command)
+			fmt.Fprintln(globalFlag.Output(), err)
+			return globals, nil, err
+		}
+	}
+	return globals, globalFlag.Args(), nil
+}
+
+// readOnlyCommands are the commands that never change the database, the only
+// ones --now can replay
+var readOnlyCommands = map[string]bool{
+	"report":    true,
+	"today":     true,
+	"list":      true,
+	"status":    true,
+	"prompt":    true,
+	"dashboard": true,
+	"export":    true,
+	"version":   true,
+	"help":      true,
+}
+
 func handleHelpCommand() {
-	fmt.Println("Usage: task [command] [arguments]")
+	fmt.Println("Usage: tomatillo [--db path] [--format text|json|csv] [command] [arguments]")
 	fmt.Println("\nCommands:")
 	fmt.Println("  add     Add a new task")
+	fmt.Println("  project Manage projects: 'project add', 'project list', 'project archive'")
 	fmt.Println("  list    List tasks")
 	fmt.Println("  update  Update the actual pomodoros of a task")
+	fmt.Println("  start   Run a pomodoro timer for a task, or a plain timer without --id")
+	fmt.Println("  status  Show the running pomodoro and the time left")
+	fmt.Println("  resume  Pick up the countdown of the running pomodoro")
+	fmt.Println("  stop    End the running pomodoro")
+	fmt.Println("  prompt  Print the running pomodoro for a shell prompt or status bar")
+	fmt.Println("  interrupt Record an interruption of the running pomodoro")
+	fmt.Println("  break   Take a short or long break")
+	fmt.Println("  ui      Full-screen view of today's tasks with a pomodoro timer")
+	fmt.Println("  config  Show or change settings")
+	fmt.Println("  db      Database maintenance: 'db migrate [--dry-run]', 'db check [--repair]'")
 	fmt.Println("  done    Mark a task as done")
 	fmt.Println("  edit    Edit the estimate of a task")
 	fmt.Println("  report  Generate a report")
 	fmt.Println("  delete  Delete a task")
 	fmt.Println("  load    Load tasks from a file")
+	fmt.Println("  serve   Serve a JSON API for tasks, pomodoros and reports")
+	fmt.Println("  dashboard Write or serve an HTML dashboard of your pomodoros")
+	fmt.Println("  export  Export the whole database as JSON")
+	fmt.Println("  import  Merge an export into the database")
 	fmt.Println("  version Print the version of the application")
 }
 
@@ -81,61 +223,20 @@ func handleAddCommand(db *sql.DB, args []string) error {
 	taskEstimate := addTaskFlag.Int("estimate", 1, "Pomodoro estimate (or use -e)")
 	addTaskFlag.StringVar(taskName, "n", "", "Task name (short version)")
 	addTaskFlag.IntVar(taskEstimate, "e", 1, "Pomodoro estimate (short version)")
+	project := addTaskFlag.String("project", "", "Project to add the task to (or use -p)")
+	addTaskFlag.StringVar(project, "p", "", "Project to add the task to (short version)")
+	var tags tagList
+	addTaskFlag.Var(&tags, "tag", "Tag to attach to the task, can be repeated (or use -t)")
+	addTaskFlag.Var(&tags, "t", "Tag to attach to the task (short version)")
 
 	addTaskFlag.Parse(args)
 
 	if *taskName == "" {
 		return fmt.Errorf("task name is required")
 	}
-	addTask(db, *taskName, *taskEstimate)
-	return nil
-}
-
-func handleLoadTasksCommand(db *sql.DB, args []string) error {
-	loadTasksFlag := flag.NewFlagSet("load", flag.ExitOnError)
-	filePath := loadTasksFlag.String("file", "", "Path to the file containing tasks and estimates")
-	// add a short version of the flag
-	loadTasksFlag.StringVar(filePath, "f", "", "Path to the file containing tasks and estimates")
-
-	loadTasksFlag.Parse(args)
-
-	if *filePath == "" {
-		return fmt.Errorf("file path is required")
-	}
-
-	file, err := os.Open(*filePath)
-	if err != nil {
-		return fmt.Errorf("failed to open file: %v", err)
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Split(line, ",")
-		if len(parts) != 2 {
-			return fmt.Errorf("invalid format in line: %s", line)
-		}
-		taskName := strings.TrimSpace(parts[0])
-		estimate, err := strconv.Atoi(strings.TrimSpace(parts[1]))
-		if err != nil {
-			return fmt.Errorf("invalid estimate in line: %s", line)
-		}
-		err = addTask(db, taskName, estimate)
-		if err != nil {
-			return fmt.Errorf("failed to add task: %v", err)
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading file: %v", err)
-	}
-
-	fmt.Println("Tasks successfully loaded from file.")
-	return nil
+	return addTaskWithDetails(db, Task{Name: *taskName, Estimate: *taskEstimate, Project: *project, Tags: tags}, clock.Now())
 }
 
-
 // Helper function to handle the 'list' command
 func handleListCommand(args []string) {
 	listTasksFlag := flag.NewFlagSet("list", flag.ExitOnError)
@@ -147,8 +248,14 @@ func handleListCommand(args []string) {
 	status := listTasksFlag.String("status", "all", "Status of tasks to show: 'all', 'done', 'todo', 'wip'")
 	listTasksFlag.StringVar(status, "s", "all", "Short version of status filter: active, completed, or all")
 
+	project := listTasksFlag.String("project", "", "Only show tasks of this project (or use -p)")
+	listTasksFlag.StringVar(project, "p", "", "Only show tasks of this project (short version)")
+
+	tag := listTasksFlag.String("tag", "", "Only show tasks with this tag (or use -t)")
+	listTasksFlag.StringVar(tag, "t", "", "Only show tasks with this tag (short version)")
+
 	listTasksFlag.Parse(args)
-    listTasks(*listDays, strings.ToLower(*status))
+    listTasks(TaskFilter{Days: *listDays, Now: clock.Now(), Status: strings.ToLower(*status), Project: *project, Tag: *tag})
 }
 
 
@@ -162,11 +269,34 @@ func handleActivateCommand(args []string) {
 		log.Println("Please provide a valid task ID.")
 		os.Exit(1)
 	}
+	requireTask(*activateTaskId)
+
+	if _, err := beginPomodoro(*activateTaskId, defaultPomodoroDuration, clock.Now()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// helper function to handle interrupting the running pomodoro of a task
+func handleInterruptCommand(args []string) {
+	interruptFlag := flag.NewFlagSet("interrupt", flag.ExitOnError)
+	interruptTaskId := interruptFlag.Int("id", 0, "Task ID of the running pomodoro")
+	reason := interruptFlag.String("reason", "", "Why the pomodoro was interrupted (or use -r)")
+	interruptFlag.StringVar(reason, "r", "", "Why the pomodoro was interrupted (short version)")
+	kind := interruptFlag.String("kind", "internal", "Kind of interruption: 'internal' or 'external' (or use -k)")
+	interruptFlag.StringVar(kind, "k", "internal", "Kind of interruption (short version)")
+	interruptFlag.Parse(args)
+
+	if *interruptTaskId <= 0 {
+		log.Println("Please provide a valid task ID.")
+		os.Exit(1)
+	}
 
-	// insert into task_tracking table
-	currentDate := time.Now().Format("2006-01-02")
-	half_hour := getHalfHour(time.Now().Hour(), time.Now().Minute())
-	insertTrackingTask(*activateTaskId, currentDate, half_hour)
+	err := interruptTask(db, *interruptTaskId, strings.ToLower(*kind), *reason, clock.Now())
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("Recorded an %s interruption for task with ID: %d\n", strings.ToLower(*kind), *interruptTaskId)
 }
 
 func handleBackfillCommand(args []string) {
@@ -180,10 +310,85 @@ func handleBackfillCommand(args []string) {
 		log.Println("Please provide a valid task ID.")
 		os.Exit(1)
 	}
-	insertTrackingTask(*backfillTaskId, *backfillTaskDate, *backfillTaskHalfHour)
+	requireTask(*backfillTaskId)
+
+	if err := insertTrackingTask(*backfillTaskId, *backfillTaskDate, *backfillTaskHalfHour); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
+
+// requireTask exits when there is no task with the given ID
+func requireTask(id int) {
+	exists, err := taskIDExists(db, id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !exists {
+		log.Printf("No task found with ID: %d\n", id)
+		os.Exit(1)
+	}
 }
 
 
+// Helper function to handle the 'db' command and its subcommands
+func handleDbCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Println("expected 'migrate' or 'check' subcommands")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "migrate":
+		migrateFlag := flag.NewFlagSet("db migrate", flag.ExitOnError)
+		dryRun := migrateFlag.Bool("dry-run", false, "Show pending migrations without applying them")
+		migrateFlag.Parse(args[1:])
+
+		applied, err := migrateDatabase(db, *dryRun)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Printf("Database is up to date at schema version %d\n", latestSchemaVersion())
+			return
+		}
+		verb := "Applied"
+		if *dryRun {
+			verb = "Would apply"
+		}
+		for _, m := range applied {
+			fmt.Printf("%s migration %d: %s\n", verb, m.version, m.description)
+		}
+	case "check":
+		checkFlag := flag.NewFlagSet("db check", flag.ExitOnError)
+		repair := checkFlag.Bool("repair", false, "Delete the orphaned rows that were found")
+		checkFlag.Parse(args[1:])
+
+		orphans, err := findOrphans(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(orphans) == 0 {
+			fmt.Println("No orphaned rows found")
+			return
+		}
+		for _, orphan := range orphans {
+			fmt.Printf("Orphaned row %d in %s points at a missing %s row\n", orphan.RowID, orphan.Table, orphan.Parent)
+		}
+		if !*repair {
+			fmt.Printf("Found %d orphaned rows, run 'tomatillo db check --repair' to delete them\n", len(orphans))
+			os.Exit(1)
+		}
+		if err := deleteOrphans(db, orphans); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Deleted %d orphaned rows\n", len(orphans))
+	default:
+		fmt.Println("expected 'migrate' or 'check' subcommands")
+		os.Exit(1)
+	}
+}
+
 // Helper function to handle the 'update' command
 func handleUpdateCommand(args []string) {
 	updateTaskFlag := flag.NewFlagSet("update", flag.ExitOnError)
@@ -194,7 +399,24 @@ func handleUpdateCommand(args []string) {
 		log.Println("Please provide a valid task ID.")
 		os.Exit(1)
 	}
-	updateActual(*taskId)
+
+	// a pomodoro started with 'activate' is completed by 'update', which counts it
+	// whether or not one is open
+	now := clock.Now()
+	session, err := getOpenSession(db, *taskId)
+	switch {
+	case err == sql.ErrNoRows:
+		err = updateActual(db, *taskId, now)
+	case err == nil:
+		var completed bool
+		if completed, err = completePomodoro(session.ID, *taskId, now); err == nil && !completed {
+			err = updateActual(db, *taskId, now)
+		}
+	}
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
 }
 
 // Helper function to handle the 'done' command
@@ -207,7 +429,7 @@ func handleDoneCommand(args []string) {
 		log.Println("Please provide a valid task ID.")
 		os.Exit(1)
 	}
-	markAsDone(*doneTaskId)
+	markAsDone(*doneTaskId, clock.Now())
 }
 
 // Helper function to handle the 'edit' command
@@ -223,27 +445,59 @@ func handleEditCommand(args []string) {
 		log.Println("Please provide a valid task ID.")
 		os.Exit(1)
 	}
-	updateEstimate(*editTaskId, *newEstimate)
+	updateEstimate(*editTaskId, *newEstimate, clock.Now())
 }
 
 // Helper function to handle the 'report' command
 func handleReportCommand(args []string) {
 	reportFlag := flag.NewFlagSet("report", flag.ExitOnError)
-	reportType := reportFlag.String("type", "monthly", "Report type: 'monthly','yearly' or 'weekly'")
+	reportType := reportFlag.String("type", "monthly", "Report type: 'today', 'blockweek', 'blockmonth', 'yearly', 'tags', 'projects' or 'accuracy'")
 	// add a short version of the flag
-	reportFlag.StringVar(reportType, "t", "weekly", "Report type: 'monthly' or 'yearly' or 'weekly'")
+	reportFlag.StringVar(reportType, "t", "weekly", "Report type: 'today', 'blockweek', 'blockmonth', 'yearly', 'tags', 'projects' or 'accuracy'")
+	options := reportOptions{now: clock.Now().Local()}
+	reportFlag.StringVar(&options.project, "project", "", "Only report on tasks of this project (or use -p)")
+	reportFlag.StringVar(&options.project, "p", "", "Only report on tasks of this project (short version)")
+	reportFlag.StringVar(&options.from, "from", "", "First day of the report, formatted 2006-01-02")
+	reportFlag.StringVar(&options.to, "to", "", "Last day of the report, formatted 2006-01-02")
+	week := reportFlag.String("week", "", "Report on an ISO week, formatted 2006-W01")
+	month := reportFlag.String("month", "", "Report on a month, formatted 2006-01")
+	year := reportFlag.String("year", "", "Report on a year, formatted 2006")
+	resolution := reportFlag.String("resolution", "30m", "Length of a slot in the block reports: '15m', '30m' or '1h'")
 	reportFlag.Parse(args)
 
+	var err error
+	if options.resolution, err = parseResolution(*resolution); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+	if err := resolveReportPeriod(&options, *week, *month, *year); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+
+	if options.project != "" {
+		if _, err := getProjectByName(db, options.project); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	}
+
 	if *reportType == "yearly" {
-		generateYearlyCountReport()
+		generateYearlyCountReport(options)
 	} else if *reportType == "blockmonth" {
-			generateMonthlyBlockReport()
+			generateMonthlyBlockReport(options)
 	} else if *reportType == "today" {
-		generateTodayReport()
+		generateTodayReport(options)
 	}  else if *reportType == "blockweek" {
-		generateWeeklyBlockReport()
+		generateWeeklyBlockReport(options)
+	} else if *reportType == "tags" {
+		generateTagReport(options)
+	} else if *reportType == "projects" {
+		generateProjectReport(options)
+	} else if *reportType == "accuracy" {
+		generateAccuracyReport(options)
 	} else {
-		generateTodayReport()
+		generateTodayReport(options)
 	}
 }
 
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration is one step in the evolution of the database schema. Migrations are
+// applied in order of version and each version is applied exactly once.
+type migration struct {
+	version     int
+	description string
+	up          func(tx *sql.Tx) error
+}
+
+// migrations lists every schema change, oldest first. Never edit or reorder an
+// existing entry, add a new one with the next version instead.
+//
+// The first migrations use CREATE TABLE IF NOT EXISTS so databases created before
+// schema versioning existed are brought under version control without changes.
+var migrations = []migration{
+	{1, "create tasks table", execStatements(`
+    CREATE TABLE IF NOT EXISTS tasks (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name TEXT NOT NULL,
+        estimate INTEGER NOT NULL,
+        actual INTEGER DEFAULT 0,
+        created_at DATETIME DEFAULT (datetime('now', 'localtime')),
+        updated_at DATETIME DEFAULT (datetime('now', 'localtime')),
+        done BOOLEAN DEFAULT 0
+    );`)},
+	{2, "create task_tracking table", execStatements(`
+    CREATE TABLE IF NOT EXISTS task_tracking (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        task_id INTEGER NOT NULL,
+        date DATE NOT NULL,
+        half_hour INTEGER NOT NULL CHECK (half_hour BETWEEN 0 AND 47),
+        task_name TEXT,
+        status TEXT DEFAULT 'active',
+        FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+        UNIQUE(task_id, date, half_hour)
+    );`)},
+	{3, "create sessions table", execStatements(`
+    CREATE TABLE IF NOT EXISTS sessions (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        task_id INTEGER NOT NULL,
+        started_at DATETIME NOT NULL,
+        ended_at DATETIME,
+        planned_seconds INTEGER NOT NULL,
+        outcome TEXT CHECK (outcome IN ('completed', 'interrupted', 'abandoned')),
+        interruption TEXT CHECK (interruption IN ('internal', 'external')),
+        reason TEXT,
+        FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+    );`)},
+	{4, "create breaks table", execStatements(`
+    CREATE TABLE IF NOT EXISTS breaks (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        kind TEXT NOT NULL CHECK (kind IN ('short', 'long')),
+        date DATE NOT NULL,
+        half_hour INTEGER NOT NULL CHECK (half_hour BETWEEN 0 AND 47),
+        started_at DATETIME NOT NULL,
+        ended_at DATETIME,
+        planned_seconds INTEGER NOT NULL
+    );`)},
+	{5, "create settings table", execStatements(`
+    CREATE TABLE IF NOT EXISTS settings (
+        key TEXT PRIMARY KEY,
+        value TEXT NOT NULL
+    );`)},
+	{6, "add projects", execStatements(`
+    CREATE TABLE projects (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name TEXT NOT NULL UNIQUE,
+        archived BOOLEAN DEFAULT 0,
+        created_at DATETIME DEFAULT (datetime('now', 'localtime'))
+    );`, `
+    ALTER TABLE tasks ADD COLUMN project_id INTEGER REFERENCES projects(id) ON DELETE SET NULL;`)},
+	{7, "add tags", execStatements(`
+    CREATE TABLE tags (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name TEXT NOT NULL UNIQUE
+    );`, `
+    CREATE TABLE task_tags (
+        task_id INTEGER NOT NULL,
+        tag_id INTEGER NOT NULL,
+        PRIMARY KEY (task_id, tag_id),
+        FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+        FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+    );`)},
+	{8, "add task due dates and notes", execStatements(`
+    ALTER TABLE tasks ADD COLUMN due_date DATE;`, `
+    ALTER TABLE tasks ADD COLUMN notes TEXT;`)},
+	{9, "index running sessions and breaks", execStatements(`
+    CREATE INDEX sessions_running ON sessions (started_at) WHERE ended_at IS NULL;`, `
+    CREATE INDEX breaks_running ON breaks (started_at) WHERE ended_at IS NULL;`)},
+	{10, "record when tracked pomodoros start and end", execStatements(`
+    ALTER TABLE task_tracking ADD COLUMN started_at DATETIME;`, `
+    ALTER TABLE task_tracking ADD COLUMN ended_at DATETIME;`)},
+	{11, "store timestamps in UTC", convertTimestampsToUTC},
+}
+
+// execStatements builds a migration step that runs plain SQL statements
+func execStatements(statements ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// latestSchemaVersion is the version a fully migrated database reports
+func latestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// schemaVersionTable records every applied migration
+const schemaVersionTable = `
+    CREATE TABLE IF NOT EXISTS schema_version (
+        version INTEGER PRIMARY KEY,
+        description TEXT NOT NULL,
+        applied_at DATETIME NOT NULL
+    );`
+
+// getSchemaVersion returns the version of the last applied migration, 0 for a
+// database that has never been migrated. It only reads, so a dry run leaves the
+// database untouched.
+func getSchemaVersion(db *sql.DB) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'`).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up schema_version table: %v", err)
+	}
+	if count == 0 {
+		return 0, nil
+	}
+
+	var version int
+	err = db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %v", err)
+	}
+	return version, nil
+}
+
+// pendingMigrations returns the migrations newer than the given version
+func pendingMigrations(version int) []migration {
+	var pending []migration
+	for _, m := range migrations {
+		if m.version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// migrateDatabase applies every pending migration inside a single transaction, so
+// a failing migration leaves the database exactly as it was. With dryRun set it
+// only reports what would be applied.
+func migrateDatabase(db *sql.DB, dryRun bool) ([]migration, error) {
+	version, err := getSchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	if version > latestSchemaVersion() {
+		return nil, fmt.Errorf("database schema version %d is newer than this tomatillo supports (%d)", version, latestSchemaVersion())
+	}
+
+	pending := pendingMigrations(version)
+	if dryRun || len(pending) == 0 {
+		return pending, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin migration: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(schemaVersionTable); err != nil {
+		return nil, fmt.Errorf("failed to create schema_version table: %v", err)
+	}
+	for _, m := range pending {
+		if err := m.up(tx); err != nil {
+			return nil, fmt.Errorf("migration %d (%s) failed: %v", m.version, m.description, err)
+		}
+		_, err := tx.Exec(`INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)`,
+			m.version, m.description, formatStoredTime(time.Now()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to record migration %d: %v", m.version, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit migrations: %v", err)
+	}
+	return pending, nil
+}
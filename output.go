@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// outputFormat is set by the global --format flag
+var outputFormat = "text"
+
+var outputFormats = []string{"text", "json", "csv"}
+
+func validateOutputFormat(format string) error {
+	for _, known := range outputFormats {
+		if format == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid format %q, expected one of: %s", format, strings.Join(outputFormats, ", "))
+}
+
+// writeRecords writes a slice of structs as JSON or CSV. Both formats take their
+// field names from the json struct tags, so the names stay the same whichever
+// format is asked for.
+func writeRecords(w io.Writer, format string, records interface{}) error {
+	value := reflect.ValueOf(records)
+	if value.Kind() != reflect.Slice {
+		return fmt.Errorf("expected a slice of records, got %s", value.Kind())
+	}
+
+	switch format {
+	case "json":
+		if value.IsNil() {
+			// an empty report is an empty array, not null
+			records = reflect.MakeSlice(value.Type(), 0, 0).Interface()
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	case "csv":
+		return writeCSV(w, value)
+	default:
+		return fmt.Errorf("format %q cannot be written as records", format)
+	}
+}
+
+func writeCSV(w io.Writer, records reflect.Value) error {
+	recordType := records.Type().Elem()
+	fields := csvFields(recordType)
+
+	writer := csv.NewWriter(w)
+	header := make([]string, len(fields))
+	for i, field := range fields {
+		header[i] = field.name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for i := 0; i < records.Len(); i++ {
+		record := records.Index(i)
+		row := make([]string, len(fields))
+		for j, field := range fields {
+			row[j] = formatCSVValue(record.Field(field.index))
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+type csvField struct {
+	name  string
+	index int
+}
+
+// csvFields lists the exported fields of a struct that have a json name
+func csvFields(recordType reflect.Type) []csvField {
+	var fields []csvField
+	for i := 0; i < recordType.NumField(); i++ {
+		name, _, _ := strings.Cut(recordType.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, csvField{name: name, index: i})
+	}
+	return fields
+}
+
+// formatCSVValue renders a field the way its JSON counterpart reads
+func formatCSVValue(value reflect.Value) string {
+	switch v := value.Interface().(type) {
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case time.Month:
+		return strconv.Itoa(int(v))
+	case []string:
+		return strings.Join(v, ";")
+	default:
+		return fmt.Sprint(v)
+	}
+}
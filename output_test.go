@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWriteRecordsJSON(t *testing.T) {
+	var out bytes.Buffer
+	tasks := []Task{{ID: 1, Name: "Fix login", Estimate: 2, Tags: []string{"bug"}}}
+
+	if err := writeRecords(&out, "json", tasks); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("failed to decode JSON output: %v", err)
+	}
+	if len(decoded) != 1 || decoded[0]["name"] != "Fix login" || decoded[0]["estimate"] != 2.0 {
+		t.Errorf("unexpected JSON output: %s", out.String())
+	}
+
+	out.Reset()
+	var empty []Task
+	if err := writeRecords(&out, "json", empty); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != "[]\n" {
+		t.Errorf("expected an empty array for no records, got %q", out.String())
+	}
+}
+
+func TestWriteRecordsCSV(t *testing.T) {
+	var out bytes.Buffer
+	tasks := []Task{{
+		ID:        1,
+		Name:      "Fix login, again",
+		Estimate:  2,
+		CreatedAt: time.Date(2024, time.September, 21, 10, 0, 0, 0, time.UTC),
+		Tags:      []string{"bug", "auth"},
+	}}
+
+	if err := writeRecords(&out, "csv", tasks); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "id,name,estimate,actual,created_at,updated_at,done,status,project,tags,internal_interruptions,external_interruptions,due_date,notes\n" +
+		"1,\"Fix login, again\",2,0,2024-09-21T10:00:00Z,,false,,,bug;auth,0,0,,\n"
+	if out.String() != expected {
+		t.Errorf("unexpected CSV output:\n%s\nwant:\n%s", out.String(), expected)
+	}
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	for _, format := range []string{"text", "json", "csv"} {
+		if err := validateOutputFormat(format); err != nil {
+			t.Errorf("expected %q to be valid, got %v", format, err)
+		}
+	}
+	if err := validateOutputFormat("xml"); err == nil {
+		t.Error("expected an error for an unknown format, but got none")
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Project groups tasks, e.g. per client
+type Project struct {
+	ID        int
+	Name      string
+	Archived  bool
+	CreatedAt time.Time
+}
+
+func addProject(db *sql.DB, name string, now time.Time) (int, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf("project name cannot be empty")
+	}
+
+	result, err := db.Exec(`INSERT INTO projects (name, created_at) VALUES (?, ?)`, name, formatStoredTime(now))
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return 0, fmt.Errorf("project %s already exists", name)
+		}
+		return 0, fmt.Errorf("failed to add project: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the ID of the inserted project: %v", err)
+	}
+	return int(id), nil
+}
+
+// errNoProject is returned when a project looked up by name does not exist
+var errNoProject = errors.New("no project found")
+
+func getProjectByName(db execer, name string) (Project, error) {
+	var project Project
+	err := db.QueryRow(`SELECT id, name, archived, created_at FROM projects WHERE name = ?`, name).
+		Scan(&project.ID, &project.Name, &project.Archived, &project.CreatedAt)
+	if err == sql.ErrNoRows {
+		return Project{}, fmt.Errorf("%w with name: %s (add it with 'tomatillo project add')", errNoProject, name)
+	} else if err != nil {
+		return Project{}, fmt.Errorf("failed to look up project: %v", err)
+	}
+	project.CreatedAt = project.CreatedAt.Local()
+	return project, nil
+}
+
+// getProjects lists projects by name, archived ones only when includeArchived is set
+func getProjects(db *sql.DB, includeArchived bool) ([]Project, error) {
+	rows, err := db.Query(`
+    SELECT id, name, archived, created_at FROM projects
+    WHERE ? OR archived = 0
+    ORDER BY name`, includeArchived)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list projects: %v", err)
+	}
+	defer rows.Close()
+
+	var projects []Project
+	for rows.Next() {
+		var project Project
+		if err := rows.Scan(&project.ID, &project.Name, &project.Archived, &project.CreatedAt); err != nil {
+			return nil, err
+		}
+		project.CreatedAt = project.CreatedAt.Local()
+		projects = append(projects, project)
+	}
+	return projects, rows.Err()
+}
+
+// ensureProject adds a project created at now unless one with that name already exists
+func ensureProject(db execer, name string, now time.Time) error {
+	_, err := db.Exec(`INSERT INTO projects (name, created_at) VALUES (?, ?) ON CONFLICT(name) DO NOTHING`,
+		name, formatStoredTime(now))
+	if err != nil {
+		return fmt.Errorf("failed to add project %s: %v", name, err)
+	}
+	return nil
+}
+
+// archiveProject hides a project from 'project list' and stops new tasks being
+// added to it. Its tasks and their history are kept.
+func archiveProject(db *sql.DB, name string) error {
+	result, err := db.Exec(`UPDATE projects SET archived = 1 WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("failed to archive project: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no project found with name: %s", name)
+	}
+	return nil
+}
+
+// getProjectBreakdown sums estimates and actuals per project for the tasks created
+// or tracked between from and to (inclusive, formatted 2006-01-02). The actual is
+// the half hours tracked in the range, so a task started earlier counts what was
+// done on it in the range. Tasks without a project are grouped under an empty
+// project name.
+func getProjectBreakdown(db *sql.DB, from, to string, project string) ([]ProjectAggregate, error) {
+	query := `
+    SELECT COALESCE(p.name, ''), COUNT(t.id), COALESCE(SUM(t.estimate), 0), COALESCE(SUM(tr.pomodoros), 0),
+        COALESCE(SUM(CASE WHEN t.done THEN 1 ELSE 0 END), 0)
+    FROM tasks t
+    LEFT JOIN projects p ON p.id = t.project_id
+    LEFT JOIN (
+        SELECT task_id, COUNT(*) AS pomodoros FROM task_tracking
+        WHERE date BETWEEN ? AND ?
+        GROUP BY task_id
+    ) tr ON tr.task_id = t.id
+    WHERE ((t.created_at >= ? AND t.created_at < ?) OR tr.task_id IS NOT NULL)
+    AND (? = '' OR p.name = ?)
+    GROUP BY p.name
+    ORDER BY p.name IS NULL, p.name`
+
+	start, end, err := storedDayRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(query, from, to, start, end, project, project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize projects: %v", err)
+	}
+	defer rows.Close()
+
+	var aggregates []ProjectAggregate
+	for rows.Next() {
+		var aggregate ProjectAggregate
+		err := rows.Scan(&aggregate.Project, &aggregate.Tasks, &aggregate.Estimate, &aggregate.Actual, &aggregate.Done)
+		if err != nil {
+			return nil, err
+		}
+		aggregates = append(aggregates, aggregate)
+	}
+	return aggregates, rows.Err()
+}
+
+// Helper function to handle the 'project' command and its subcommands
+func handleProjectCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Println("expected 'add', 'list' or 'archive' subcommands")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "add":
+		addFlag := flag.NewFlagSet("project add", flag.ExitOnError)
+		name := addFlag.String("name", "", "Project name (or use -n)")
+		addFlag.StringVar(name, "n", "", "Project name (short version)")
+		addFlag.Parse(args[1:])
+
+		id, err := addProject(db, *name, clock.Now())
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Added project: %s\nID: %d\n", strings.TrimSpace(*name), id)
+	case "list":
+		listFlag := flag.NewFlagSet("project list", flag.ExitOnError)
+		all := listFlag.Bool("all", false, "Include archived projects")
+		listFlag.Parse(args[1:])
+
+		projects, err := getProjects(db, *all)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%-3s   %-46s   %-12s   %-8s\n", "ID", "Name", "Created", "Archived")
+		fmt.Println(strings.Repeat("═", 80))
+		for _, project := range projects {
+			archived := "No"
+			if project.Archived {
+				archived = "Yes"
+			}
+			fmt.Printf("%-3d   %-46s   %-12s   %-8s\n", project.ID, project.Name, formatDate(project.CreatedAt), archived)
+		}
+	case "archive":
+		archiveFlag := flag.NewFlagSet("project archive", flag.ExitOnError)
+		name := archiveFlag.String("name", "", "Project name (or use -n)")
+		archiveFlag.StringVar(name, "n", "", "Project name (short version)")
+		archiveFlag.Parse(args[1:])
+
+		if err := archiveProject(db, *name); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Project %s has been archived\n", *name)
+	default:
+		fmt.Println("expected 'add', 'list' or 'archive' subcommands")
+		os.Exit(1)
+	}
+}
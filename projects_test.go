@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestProjects(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	if _, err := addProject(db, "Acme", time.Now()); err != nil {
+		t.Fatalf("failed to add project: %v", err)
+	}
+	if _, err := addProject(db, "Acme", time.Now()); err == nil {
+		t.Error("expected an error when adding a duplicate project, but got none")
+	}
+	if _, err := addProject(db, "Globex", time.Now()); err != nil {
+		t.Fatalf("failed to add project: %v", err)
+	}
+
+	tasks := []Task{
+		{Name: "Acme 1", Estimate: 3, Project: "Acme"},
+		{Name: "Acme 2", Estimate: 2, Project: "Acme"},
+		{Name: "Loose task", Estimate: 1},
+	}
+	for _, task := range tasks {
+		if _, err := createTask(db, task, time.Now()); err != nil {
+			t.Fatalf("failed to add task: %v", err)
+		}
+	}
+	if _, err := createTask(db, Task{Name: "Missing", Estimate: 1, Project: "Initech"}, time.Now()); err == nil {
+		t.Error("expected an error when adding a task to a missing project, but got none")
+	}
+
+	acmeTasks, err := getFilteredTasks(TaskFilter{Days: 1, Now: time.Now(), Status: "all", Project: "Acme"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(acmeTasks) != 2 {
+		t.Errorf("expected 2 Acme tasks, got %d", len(acmeTasks))
+	}
+
+	today := formatDate(time.Now())
+	breakdown, err := getProjectBreakdown(db, today, today, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(breakdown) != 2 {
+		t.Fatalf("expected 2 rows in the breakdown, got %d", len(breakdown))
+	}
+	if breakdown[0].Project != "Acme" || breakdown[0].Tasks != 2 || breakdown[0].Estimate != 5 {
+		t.Errorf("unexpected Acme breakdown: %+v", breakdown[0])
+	}
+	if breakdown[1].Project != "" || breakdown[1].Tasks != 1 {
+		t.Errorf("expected tasks without a project last, got %+v", breakdown[1])
+	}
+
+	if err := archiveProject(db, "Globex"); err != nil {
+		t.Fatalf("failed to archive project: %v", err)
+	}
+	if _, err := createTask(db, Task{Name: "Late", Estimate: 1, Project: "Globex"}, time.Now()); err == nil {
+		t.Error("expected an error when adding a task to an archived project, but got none")
+	}
+
+	projects, err := getProjects(db, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(projects) != 1 {
+		t.Errorf("expected archived projects to be hidden, got %d projects", len(projects))
+	}
+	projects, err = getProjects(db, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(projects) != 2 {
+		t.Errorf("expected 2 projects including archived ones, got %d", len(projects))
+	}
+}
+
+func TestProjectBreakdownCountsTrackedPomodoros(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	if _, err := addProject(db, "Acme", time.Now()); err != nil {
+		t.Fatalf("failed to add project: %v", err)
+	}
+	// a task from last month that was worked on this week, and a new one that was not
+	created := []string{"2026-08-03 09:00", "2026-09-15 09:00"}
+	for i, at := range created {
+		createdAt, _ := time.ParseInLocation("2006-01-02 15:04", at, time.Local)
+		_, err := db.Exec(`INSERT INTO tasks (name, estimate, actual, created_at, updated_at, project_id) VALUES (?, 4, 3, ?, ?, 1)`,
+			fmt.Sprintf("Task %d", i+1), formatStoredTime(createdAt), formatStoredTime(createdAt))
+		if err != nil {
+			t.Fatalf("failed to add task: %v", err)
+		}
+	}
+	for _, tracked := range []struct {
+		date     string
+		halfHour int
+	}{{"2026-08-03", 18}, {"2026-09-14", 20}, {"2026-09-16", 21}} {
+		if err := insertTrackingTask(1, tracked.date, tracked.halfHour); err != nil {
+			t.Fatalf("failed to track task: %v", err)
+		}
+	}
+
+	breakdown, err := getProjectBreakdown(db, "2026-09-14", "2026-09-20", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(breakdown) != 1 || breakdown[0].Tasks != 2 || breakdown[0].Estimate != 8 || breakdown[0].Actual != 2 {
+		t.Errorf("expected both tasks with the 2 half hours tracked this week, got %+v", breakdown)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// promptFormats are the outputs of 'tomatillo prompt'
+var promptFormats = []string{"ps1", "tmux", "i3bar", "waybar-json"}
+
+// promptNameLength keeps long task names from taking over the status bar
+const promptNameLength = 24
+
+// promptState is what the prompt shows: a running pomodoro, a break or nothing
+type promptState struct {
+	Kind      string // "pomodoro", "break" or "idle"
+	Remaining time.Duration
+	Planned   time.Duration
+	Task      string // the task of the pomodoro, the kind of break
+}
+
+// getPromptState looks up the running pomodoro or break. It only reads the open
+// session and break, so it stays fast enough to run on every prompt.
+func getPromptState(db *sql.DB, now time.Time) (promptState, error) {
+	session, err := getRunningSession(db)
+	if err == nil {
+		return promptState{"pomodoro", clampRemaining(session.Deadline().Sub(now)), session.Planned, session.TaskName}, nil
+	} else if err != sql.ErrNoRows {
+		return promptState{}, err
+	}
+
+	b, err := getRunningBreak(db)
+	if err == sql.ErrNoRows {
+		return promptState{Kind: "idle"}, nil
+	} else if err != nil {
+		return promptState{}, err
+	}
+	// a break whose terminal was closed is never ended, so it stops counting once it is over
+	remaining := b.StartedAt.Add(b.Planned).Sub(now)
+	if remaining <= 0 {
+		return promptState{Kind: "idle"}, nil
+	}
+	return promptState{"break", remaining, b.Planned, b.Kind + " break"}, nil
+}
+
+func clampRemaining(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// promptText is the short form shared by every format, e.g. "🍅 12:34 Fix login"
+func promptText(state promptState) string {
+	switch state.Kind {
+	case "pomodoro":
+		name := state.Task
+		if runes := []rune(name); len(runes) > promptNameLength {
+			name = string(runes[:promptNameLength-1]) + "…"
+		}
+		return fmt.Sprintf("🍅 %s %s", formatCountdown(state.Remaining), name)
+	case "break":
+		return fmt.Sprintf("☕ %s", formatCountdown(state.Remaining))
+	}
+	return "🍅 idle"
+}
+
+// promptClass names the state for styling; an overdue pomodoro waits for 'stop'
+func promptClass(state promptState) string {
+	if state.Kind == "pomodoro" && state.Remaining <= 0 {
+		return "overdue"
+	}
+	return state.Kind
+}
+
+// formatPrompt renders the state for a shell prompt or a status bar
+func formatPrompt(format string, state promptState) (string, error) {
+	text := promptText(state)
+	class := promptClass(state)
+
+	switch format {
+	case "ps1":
+		// an idle prompt stays out of the way
+		if class == "idle" {
+			return "", nil
+		}
+		return text + " ", nil
+	case "tmux":
+		colors := map[string]string{"pomodoro": "red", "overdue": "yellow", "break": "blue", "idle": "default"}
+		return fmt.Sprintf("#[fg=%s]%s#[default]", colors[class], text), nil
+	case "i3bar":
+		colors := map[string]string{"pomodoro": "#ff5555", "overdue": "#f1fa8c", "break": "#8be9fd", "idle": "#888888"}
+		// the short text drops the task name when the bar runs out of room
+		shortText := text
+		if state.Kind == "pomodoro" {
+			shortText = "🍅 " + formatCountdown(state.Remaining)
+		}
+		block := struct {
+			Name      string `json:"name"`
+			FullText  string `json:"full_text"`
+			ShortText string `json:"short_text"`
+			Color     string `json:"color"`
+		}{"tomatillo", text, shortText, colors[class]}
+		out, err := json.Marshal(block)
+		return string(out), err
+	case "waybar-json":
+		module := struct {
+			Text       string `json:"text"`
+			Tooltip    string `json:"tooltip"`
+			Class      string `json:"class"`
+			Alt        string `json:"alt"`
+			Percentage int    `json:"percentage"`
+		}{Text: text, Tooltip: promptTooltip(state), Class: class, Alt: class}
+		if state.Planned > 0 {
+			module.Percentage = int(100 * (state.Planned - state.Remaining) / state.Planned)
+		}
+		out, err := json.Marshal(module)
+		return string(out), err
+	}
+	return "", fmt.Errorf("invalid prompt format %q, expected %s", format, strings.Join(promptFormats, ", "))
+}
+
+// promptTooltip spells the state out for status bars that show a tooltip
+func promptTooltip(state promptState) string {
+	switch {
+	case state.Kind == "idle":
+		return "No pomodoro is running"
+	case state.Kind == "pomodoro" && state.Remaining <= 0:
+		return fmt.Sprintf("%s: run 'tomatillo stop' to count the pomodoro", state.Task)
+	}
+	return fmt.Sprintf("%s: %s left of %s", state.Task, formatCountdown(state.Remaining), formatCountdown(state.Planned))
+}
+
+// Helper function to handle the 'prompt' command
+func handlePromptCommand(args []string) {
+	promptFlag := flag.NewFlagSet("prompt", flag.ExitOnError)
+	format := promptFlag.String("format", "ps1", "Output for 'ps1', 'tmux', 'i3bar' or 'waybar-json'")
+	promptFlag.Parse(args)
+
+	state, err := getPromptState(db, clock.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+	out, err := formatPrompt(*format, state)
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+
+	// PS1 is spliced into the prompt as is, the status bars read a line each
+	if *format == "ps1" {
+		fmt.Print(out)
+	} else {
+		fmt.Println(out)
+	}
+}
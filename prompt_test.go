@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFormatPrompt(t *testing.T) {
+	running := promptState{"pomodoro", 12*time.Minute + 34*time.Second, 25 * time.Minute, "Fix login"}
+	overdue := promptState{"pomodoro", 0, 25 * time.Minute, "Fix login"}
+	rest := promptState{"break", 3 * time.Minute, 5 * time.Minute, "short break"}
+	idle := promptState{Kind: "idle"}
+
+	tests := []struct {
+		format   string
+		state    promptState
+		expected string
+	}{
+		{"ps1", running, "🍅 12:34 Fix login "},
+		{"ps1", rest, "☕ 03:00 "},
+		{"ps1", idle, ""},
+		{"tmux", running, "#[fg=red]🍅 12:34 Fix login#[default]"},
+		{"tmux", overdue, "#[fg=yellow]🍅 00:00 Fix login#[default]"},
+		{"tmux", idle, "#[fg=default]🍅 idle#[default]"},
+		{"i3bar", running, `{"name":"tomatillo","full_text":"🍅 12:34 Fix login","short_text":"🍅 12:34","color":"#ff5555"}`},
+	}
+
+	for _, tt := range tests {
+		result, err := formatPrompt(tt.format, tt.state)
+		if err != nil {
+			t.Fatalf("formatPrompt(%q) returned an error: %v", tt.format, err)
+		}
+		if result != tt.expected {
+			t.Errorf("formatPrompt(%q, %+v) = %q; want %q", tt.format, tt.state, result, tt.expected)
+		}
+	}
+
+	if _, err := formatPrompt("zsh", idle); err == nil {
+		t.Error("expected an error for an unknown format, but got none")
+	}
+}
+
+func TestFormatPromptWaybar(t *testing.T) {
+	out, err := formatPrompt("waybar-json", promptState{"pomodoro", 10 * time.Minute, 25 * time.Minute, "Fix login"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var module map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &module); err != nil {
+		t.Fatalf("expected valid JSON, got %q: %v", out, err)
+	}
+	if module["text"] != "🍅 10:00 Fix login" || module["class"] != "pomodoro" || module["percentage"] != 60.0 {
+		t.Errorf("unexpected waybar module: %v", module)
+	}
+}
+
+func TestGetPromptState(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	now := time.Date(2024, time.September, 21, 10, 0, 0, 0, time.Local)
+	if state, err := getPromptState(db, now); err != nil || state.Kind != "idle" {
+		t.Fatalf("expected an idle state, got %+v (%v)", state, err)
+	}
+
+	if _, err := startBreak(db, "short", 5*time.Minute, now.Add(-2*time.Minute)); err != nil {
+		t.Fatalf("failed to start break: %v", err)
+	}
+	if state, _ := getPromptState(db, now); state.Kind != "break" || state.Remaining != 3*time.Minute {
+		t.Errorf("expected a break with 3 minutes left, got %+v", state)
+	}
+	if state, _ := getPromptState(db, now.Add(time.Hour)); state.Kind != "idle" {
+		t.Errorf("expected a break that is long over to be idle, got %+v", state)
+	}
+
+	// an activated task shows up as the running pomodoro
+	if err := addTask(db, "Fix login", 2, time.Now()); err != nil {
+		t.Fatalf("failed to add task: %v", err)
+	}
+	if _, err := startSession(db, 1, defaultPomodoroDuration, now); err != nil {
+		t.Fatalf("failed to start session: %v", err)
+	}
+	state, err := getPromptState(db, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state.Kind != "pomodoro" || state.Task != "Fix login" || state.Remaining != 24*time.Minute {
+		t.Errorf("expected the pomodoro on Fix login with 24 minutes left, got %+v", state)
+	}
+}
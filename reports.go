@@ -1,24 +1,157 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	"os"
 	"strings"
 	"time"
 
 )
 
-// Function to wrap text in color
-func colorize(text, color string) string {
-    return fmt.Sprintf("\033[%sm%s\033[0m", color, text)
+// reportOptions holds the flags shared by every report type
+type reportOptions struct {
+    project string // only report on the tasks of this project when set
+    from    string // first day of the report, formatted 2006-01-02
+    to      string // last day of the report, formatted 2006-01-02
+    resolution time.Duration // length of a block report slot: 15m, 30m or 1h, 30m when zero
+    now     time.Time // the time the report is drawn at, which picks the default period
+}
+
+// blockResolutions are the slot lengths the block reports can be drawn with
+var blockResolutions = []time.Duration{15 * time.Minute, 30 * time.Minute, time.Hour}
+
+// parseResolution reads a --resolution flag such as 15m or 1h
+func parseResolution(value string) (time.Duration, error) {
+    resolution, err := time.ParseDuration(value)
+    if err == nil {
+        for _, known := range blockResolutions {
+            if resolution == known {
+                return resolution, nil
+            }
+        }
+    }
+    return 0, fmt.Errorf("invalid resolution %q, expected 15m, 30m or 1h", value)
+}
+
+// slotsPerHour is how many block report slots make up an hour
+func (options reportOptions) slotsPerHour() int {
+    if options.resolution <= 0 {
+        return 2
+    }
+    return int(time.Hour / options.resolution)
+}
+
+// resolveReportPeriod checks --from and --to and turns --week, --month or --year
+// into them, so the reports only have to look at from and to
+func resolveReportPeriod(options *reportOptions, week, month, year string) error {
+    for _, date := range []string{options.from, options.to} {
+        if _, err := time.Parse("2006-01-02", date); date != "" && err != nil {
+            return fmt.Errorf("invalid date %q, expected the format 2006-01-02", date)
+        }
+    }
+
+    var periods []string
+    for _, period := range []string{week, month, year} {
+        if period != "" {
+            periods = append(periods, period)
+        }
+    }
+    if len(periods) > 1 || (len(periods) == 1 && (options.from != "" || options.to != "")) {
+        return fmt.Errorf("choose one of --from/--to, --week, --month or --year")
+    }
+
+    var start, end time.Time
+    var err error
+    switch {
+    case week != "":
+        start, end, err = parseWeek(week)
+    case month != "":
+        start, end, err = parseMonth(month)
+    case year != "":
+        start, end, err = parseYear(year)
+    default:
+        if options.from != "" && options.to != "" && options.from > options.to {
+            return fmt.Errorf("--from %s is after --to %s", options.from, options.to)
+        }
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    options.from, options.to = formatDate(start), formatDate(end)
+    return nil
+}
+
+// periodTitle names a report and its days (formatted 2006-01-02), with the ISO
+// week when they make up exactly one week
+func periodTitle(name string, from, to string) string {
+    start, startErr := time.ParseInLocation("2006-01-02", from, time.Local)
+    end, endErr := time.ParseInLocation("2006-01-02", to, time.Local)
+    if startErr == nil && endErr == nil {
+        if week := weekLabel(start, end); week != "" {
+            return fmt.Sprintf("%s %s (%s to %s)", name, week, from, to)
+        }
+    }
+    return fmt.Sprintf("%s (%s to %s)", name, from, to)
+}
+
+// reportPeriod returns the days from --from to --to, falling back to start and
+// end for the ones that were not given
+func reportPeriod(options reportOptions, start, end time.Time) (time.Time, time.Time) {
+    if from, err := time.ParseInLocation("2006-01-02", options.from, time.Local); err == nil {
+        start = from
+    }
+    if to, err := time.ParseInLocation("2006-01-02", options.to, time.Local); err == nil {
+        end = to
+    }
+    return start, end
+}
+
+// printRecords writes records in the JSON or CSV --format and reports whether it
+// did, so the text reports can go on to draw their boxes otherwise
+func printRecords(records interface{}) bool {
+    if outputFormat == "text" {
+        return false
+    }
+    if err := writeRecords(os.Stdout, outputFormat, records); err != nil {
+        log.Fatal(err)
+    }
+    return true
+}
+
+// printTrackingRecords writes the tracking rows of every day from start to end
+func printTrackingRecords(start, end time.Time, options reportOptions) bool {
+    if outputFormat == "text" {
+        return false
+    }
+
+    tracking, err := getTrackingForRange(start, end, options.project)
+    if err != nil {
+        log.Fatal(err)
+    }
+    return printRecords(tracking)
 }
 
-func checkTaskForHalfHour(halfHour int, taskMap map[int]string) string {
-    // Check if a task exists in the specific half-hour slot
-    if status, exists := taskMap[halfHour]; exists {
-        return status // Return emoji if a task is found
+// getTrackingForRange returns the tracking rows of every day from start to end
+func getTrackingForRange(start, end time.Time, project string) ([]TaskTracking, error) {
+    tracking := []TaskTracking{}
+    for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+        rows, err := getProjectTasksForDay(formatDate(day), project)
+        if err != nil {
+            return nil, err
+        }
+        tracking = append(tracking, rows...)
     }
-    return "·" // No task found, this is a middle dot, not a period.
+    return tracking, nil
+}
+
+// Function to wrap text in color
+func colorize(text, color string) string {
+    return fmt.Sprintf("\033[%sm%s\033[0m", color, text)
 }
 
 func formatDate(t time.Time) string {
@@ -34,112 +167,312 @@ func getHalfHour(hour int, minute int) int {
     }
 }
 
-func generateDailyBlock(date string) {
-    tasks, err := getTasksForDay(date)
+func generateDailyBlock(date string, options reportOptions) {
+    writeDailyBlock(os.Stdout, date, options)
+}
+
+// writeDailyBlock draws the half-hour strip of a day, shared by the block
+// reports and the ui. Every slot a pomodoro or break overlapped is shaded by how
+// much of it was taken: ░ for up to a third, ▒ for up to two thirds, ▓ above.
+func writeDailyBlock(w io.Writer, date string, options reportOptions) {
+    day, err := time.ParseInLocation("2006-01-02", date, time.Local)
     if err != nil {
-        fmt.Println("Error fetching tasks:", err)
+        fmt.Fprintln(w, "Error parsing date:", err)
         return
     }
-    
-    // Initialize a map to track task status for each half-hour
-    taskMap := make(map[int]string)
-    for _, task := range tasks {
-        taskMap[task.HalfHour] = colorize("▓", "32") // Use a tomato emoji for completed Pomodoros
+    // a pomodoro started before midnight can spill into this day
+    previous := formatDate(day.AddDate(0, 0, -1))
+
+    var tasks []TaskTracking
+    for _, d := range []string{previous, date} {
+        rows, err := getProjectTasksForDay(d, options.project)
+        if err != nil {
+            fmt.Fprintln(w, "Error fetching tasks:", err)
+            return
+        }
+        tasks = append(tasks, rows...)
+    }
+
+    // breaks do not belong to a project, so only show them in the full report
+    var breaks []Break
+    if options.project == "" {
+        for _, d := range []string{previous, date} {
+            rows, err := getBreaksForDay(d)
+            if err != nil {
+                fmt.Fprintln(w, "Error fetching breaks:", err)
+                return
+            }
+            breaks = append(breaks, rows...)
+        }
+    }
+
+    perHour := options.slotsPerHour()
+    work := slotOccupancy(trackingSpans(tasks, options.now), day, 24*perHour)
+    rest := slotOccupancy(breakSpans(breaks, options.now), day, 24*perHour)
+
+    fmt.Fprintf(w, "║ %s ", date)
+
+    // Loop through the slots of the day, with a space after every hour
+    for i := range work {
+        switch {
+        case work[i] > 0:
+            fmt.Fprint(w, colorize(occupancyGlyph(work[i]), "32")) // Work wins if a break shares the slot
+        case rest[i] > 0:
+            fmt.Fprint(w, colorize(occupancyGlyph(rest[i]), "34")) // Breaks are blue
+        default:
+            fmt.Fprint(w, "·") // this is a middle dot, not a period
+        }
+        if i%perHour == perHour-1 {
+            fmt.Fprint(w, " ")
+        }
     }
+    fmt.Fprintln(w, "║")
+}
 
+// blockWidth is the inner width of a block report box: the date column and an
+// hour of slots plus a space for each hour of the day
+func blockWidth(options reportOptions) int {
+    return 12 + 24*(options.slotsPerHour()+1)
+}
 
-    // Print the header for hours
-   
-    fmt.Printf("║ %s ", date)
+// printBlockHeader draws the title and the hour ruler of a block report
+func printBlockHeader(title string, options reportOptions) {
+    width := blockWidth(options)
+    titleWidth := len([]rune(title)) + 2
+    fmt.Println("╔" + strings.Repeat("═", titleWidth) + "╗ ")
+    fmt.Printf("║ %s ║ \n", title)
+    fmt.Println("╠" + strings.Repeat("═", titleWidth) + "╩" + strings.Repeat("═", width-titleWidth-1) + "╗ ")
+    fmt.Println("║" + strings.Repeat(" ", 12) + hourRuler(options.slotsPerHour()) + "║ ")
+    fmt.Println("╠" + strings.Repeat("═", width) + "╣ ")
+}
 
-    // Loop through the 48 half-hour slots (0 to 47)
-    for i := 0; i < 48; i += 2 {
-        // Check if tasks exist in each half-hour slot
-        firstHalf := checkTaskForHalfHour(i, taskMap)
-        secondHalf := checkTaskForHalfHour(i + 1, taskMap)
+// printBlockFooter closes a block report box
+func printBlockFooter(options reportOptions) {
+    fmt.Println("╚" + strings.Repeat("═", blockWidth(options)) + "╝ ")
+}
 
-        // Print the status for the two half-hour slots in each hour
-        fmt.Printf("%s%s ", firstHalf, secondHalf)
-        
+// hourRuler labels the hours above the slots. When an hour is a single slot
+// only every other hour has room for its label.
+func hourRuler(perHour int) string {
+    var ruler strings.Builder
+    for hour := 0; hour < 24; hour++ {
+        separator := "|"
+        if hour == 23 {
+            separator = " "
+        }
+        if perHour == 1 {
+            if hour%2 == 0 {
+                fmt.Fprintf(&ruler, "%02d", hour)
+            } else {
+                ruler.WriteString("  ")
+            }
+            continue
+        }
+        fmt.Fprintf(&ruler, "%-*s%s", perHour, fmt.Sprintf("%02d", hour), separator)
     }
-    fmt.Println("║")
+    return ruler.String()
 }
 
+// span is the time a pomodoro or break took
+type span struct {
+    start, end time.Time
+}
+
+// trackingSpans turns tracking rows into the time they took. A running pomodoro
+// lasts as long as its session was planned, 25 minutes when it has no session.
+// Rows tracked before timestamps were recorded fill their whole half hour.
+func trackingSpans(rows []TaskTracking, now time.Time) []span {
+    var spans []span
+    for _, row := range rows {
+        if row.StartedAt == nil {
+            day, err := time.ParseInLocation("2006-01-02", row.Date, time.Local)
+            if err != nil {
+                continue
+            }
+            start := time.Date(day.Year(), day.Month(), day.Day(), 0, row.HalfHour*30, 0, 0, time.Local)
+            spans = append(spans, span{start, start.Add(30 * time.Minute)})
+            continue
+        }
+        var ended sql.NullTime
+        if row.EndedAt != nil {
+            ended = sql.NullTime{Time: *row.EndedAt, Valid: true}
+        }
+        planned := row.Planned
+        if planned <= 0 {
+            planned = defaultPomodoroDuration
+        }
+        spans = append(spans, span{*row.StartedAt, spanEnd(*row.StartedAt, ended, planned, now)})
+    }
+    return spans
+}
+
+// breakSpans turns breaks into the time they took
+func breakSpans(breaks []Break, now time.Time) []span {
+    var spans []span
+    for _, b := range breaks {
+        spans = append(spans, span{b.StartedAt, spanEnd(b.StartedAt, b.EndedAt, b.Planned, now)})
+    }
+    return spans
+}
+
+// spanEnd is when something that started at start ended. While it is still
+// running it lasts until now, but never beyond what was planned.
+func spanEnd(start time.Time, ended sql.NullTime, planned time.Duration, now time.Time) time.Time {
+    if ended.Valid {
+        return ended.Time
+    }
+    deadline := start.Add(planned)
+    if now.After(start) && now.Before(deadline) {
+        return now
+    }
+    return deadline
+}
+
+// slotOccupancy splits a day into equal slots by the clock and returns how much
+// of each slot the spans cover, from 0 to 1
+func slotOccupancy(spans []span, day time.Time, slots int) []float64 {
+    minutes := 24 * 60 / slots
+    occupancy := make([]float64, slots)
+    for i := range occupancy {
+        slotStart := time.Date(day.Year(), day.Month(), day.Day(), 0, i*minutes, 0, 0, day.Location())
+        slotEnd := time.Date(day.Year(), day.Month(), day.Day(), 0, (i+1)*minutes, 0, 0, day.Location())
+
+        var covered time.Duration
+        for _, s := range spans {
+            start, end := s.start, s.end
+            if start.Before(slotStart) {
+                start = slotStart
+            }
+            if end.After(slotEnd) {
+                end = slotEnd
+            }
+            if end.After(start) {
+                covered += end.Sub(start)
+            }
+        }
+        occupancy[i] = math.Min(1, float64(covered)/float64(slotEnd.Sub(slotStart)))
+    }
+    return occupancy
+}
+
+// occupancyGlyph shades a slot by how much of it was taken
+func occupancyGlyph(occupancy float64) string {
+    switch {
+    case occupancy < 1.0/3:
+        return "░"
+    case occupancy < 2.0/3:
+        return "▒"
+    default:
+        return "▓"
+    }
+}
 
-// Generate a weekly block report
-func generateWeeklyBlockReport() {
-    now := time.Now().Local()
-    startOfWeek, endOfWeek := getWeek(now)
-    fmt.Println("╔══════════════════════════════════════════╗ ")
-    fmt.Printf( "║ Weekly Report (%s to %s) ║ \n", startOfWeek.Format("2006-01-02"), endOfWeek.Format("2006-01-02"))
-    fmt.Println("╠══════════════════════════════════════════╩═════════════════════════════════════════╗ ")
-    fmt.Println("║            00|01|02|03|04|05|06|07|08|09|10|11|12|13|14|15|16|17|18|19|20|21|22|23 ║ ")
-    fmt.Println("╠════════════════════════════════════════════════════════════════════════════════════╣ ")
+
+// Generate a weekly block report, for the current week unless a period is given
+func generateWeeklyBlockReport(options reportOptions) {
+    startOfWeek, endOfWeek := getWeek(options.now)
+    startOfWeek, endOfWeek = reportPeriod(options, startOfWeek, endOfWeek)
+    if printTrackingRecords(startOfWeek, endOfWeek, options) {
+        return
+    }
+    printBlockHeader(periodTitle("Weekly Report", formatDate(startOfWeek), formatDate(endOfWeek)), options)
 
     // Iterate through each day of the week
-    for i := 0; i < 7; i++ {
-        day := time.Now().Local().AddDate(0, 0, -int(time.Now().Weekday())+i).Format("2006-01-02")
-        generateDailyBlock(day)  // Reuse your daily report generation
+    for day := startOfWeek; !day.After(endOfWeek); day = day.AddDate(0, 0, 1) {
+        generateDailyBlock(formatDate(day), options)  // Reuse your daily report generation
     }
 
-    fmt.Println("╚════════════════════════════════════════════════════════════════════════════════════╝ ")
+    printBlockFooter(options)
+    generateProjectBreakdown(formatDate(startOfWeek), formatDate(endOfWeek), options)
 }
 
-// Generate a monthly block report
-func generateMonthlyBlockReport() {
-    now := time.Now().Local()
-    startOfMonth, endOfMonth := getMonth(now)
-    fmt.Println("╔═══════════════════════════════════════════╗ ")
-    fmt.Printf( "║ Monthly Report (%s to %s) ║ \n", startOfMonth.Format("2006-01-02"), endOfMonth.Format("2006-01-02"))
-    fmt.Println("╠═══════════════════════════════════════════╩════════════════════════════════════════╗ ")
-    fmt.Println("║            00|01|02|03|04|05|06|07|08|09|10|11|12|13|14|15|16|17|18|19|20|21|22|23 ║ ")
-    fmt.Println("╠════════════════════════════════════════════════════════════════════════════════════╣ ")
+// Generate a monthly block report, for the current month unless a period is given
+func generateMonthlyBlockReport(options reportOptions) {
+    startOfMonth, endOfMonth := getMonth(options.now)
+    startOfMonth, endOfMonth = reportPeriod(options, startOfMonth, endOfMonth)
+    if printTrackingRecords(startOfMonth, endOfMonth, options) {
+        return
+    }
+    printBlockHeader(periodTitle("Monthly Report", formatDate(startOfMonth), formatDate(endOfMonth)), options)
 
     // Iterate through each day of the month
     for day := startOfMonth; !day.After(endOfMonth); day = day.AddDate(0, 0, 1) {
         dayStr := day.Format("2006-01-02")
         //fmt.Printf("\n%s\n", day)
-        generateDailyBlock(dayStr)  // Reuse your daily report generation
+        generateDailyBlock(dayStr, options)  // Reuse your daily report generation
     }
 
-    fmt.Println("╚════════════════════════════════════════════════════════════════════════════════════╝ ")
+    printBlockFooter(options)
+    generateProjectBreakdown(formatDate(startOfMonth), formatDate(endOfMonth), options)
+}
+
+// getYearlyRecords returns the daily counts of every year the period touches,
+// the current year unless a period is given
+func getYearlyRecords(db *sql.DB, options reportOptions) ([]TaskTrackingAggregate, error) {
+    start, end := getYear(options.now)
+    start, end = reportPeriod(options, start, end)
+    records := []TaskTrackingAggregate{}
+    for year := start.Year(); year <= end.Year(); year++ {
+        reports, err := getProjectYearlyData(db, year, options.project)
+        if err != nil {
+            return nil, err
+        }
+        for _, report := range reports {
+            day := time.Date(report.Year, report.Month, report.Day, 0, 0, 0, 0, start.Location())
+            if !day.Before(start) && !day.After(end) {
+                records = append(records, report)
+            }
+        }
+    }
+    return records, nil
 }
 
 // generate a report for yearly data of tasks completed. each row is a month and each column is a day
-func generateYearlyCountReport() {
-    
-    t := time.Now().Local()
-    year := t.Year()
-
-    reports, _ := getYearlyData(db, year)
-    currentYear := time.Date(year, 1, 1, 0, 0, 0, 0, time.Local)
-    
-    var currentMonth time.Month
-    var lastDay int // February is special
+func generateYearlyCountReport(options reportOptions) {
+    reports, err := getYearlyRecords(db, options)
+    if err != nil {
+        log.Fatal(err)
+    }
+    if printRecords(reports) {
+        return
+    }
+
+    // draw a box for every year in the period
+    for len(reports) > 0 {
+        year := reports[0].Year
+        end := 0
+        for end < len(reports) && reports[end].Year == year {
+            end++
+        }
+        drawYearlyCounts(year, reports[:end])
+        reports = reports[end:]
+    }
+}
+
+// drawYearlyCounts draws the daily counts of a year, a row per month. Days
+// outside the period are left blank
+func drawYearlyCounts(year int, reports []TaskTrackingAggregate) {
+    first, last := reports[0], reports[len(reports)-1]
 
     fmt.Println("╔═══════════════════════════════════════════╗ ")
-    fmt.Printf( "║ Yearly Report (%s-01-01 to %s-12-31)  ║  \n", currentYear.Format("2006"), currentYear.Format("2006")) 
+    fmt.Printf( "║ Yearly Report (%d-%02d-%02d to %d-%02d-%02d)  ║  \n", year, first.Month, first.Day, year, last.Month, last.Day)
     fmt.Println("╠═══════════════════════════════════════════╩═══════════════════════════════════════════════════════╗ ")
     fmt.Print("║       01 02 03 04 05 06 07 08 09 10 11 12 13 14 15 16 17 18 19 20 21 22 23 24 25 26 27 28 29 30 31║ ")
 
+    var currentMonth time.Month
+    var lastDay int
     for _, report := range reports {
-        // Check if the month changes, and print the header for a new month
+        // Close the row of the previous month and start a new one
         if report.Month != currentMonth {
-
-            currentMonth = report.Month
-            monthAbbrev := getMonthAbbreviation(currentMonth)
-            if currentMonth == 3 && lastDay == 29 {
-                fmt.Print("      ║")
-            } else if currentMonth == 3 && lastDay == 28 {
-                fmt.Print("         ║")    
-            } else if currentMonth == 5 || currentMonth == 7 || currentMonth == 10 || currentMonth == 12 {
-                fmt.Print("   ║")             
-            } else if currentMonth.String() != "January" {
-                fmt.Print("║")
+            if currentMonth != 0 {
+                fmt.Print(strings.Repeat("   ", 31-lastDay) + "║")
             }
-            fmt.Printf("\n║ %s  ", monthAbbrev)
+            currentMonth = report.Month
+            fmt.Printf("\n║ %s  ", getMonthAbbreviation(currentMonth))
+            lastDay = 0
         }
-            
+        fmt.Print(strings.Repeat("   ", report.Day-lastDay-1))
+
         // Print each day's task count
         if report.TaskCount == 0 {
             fmt.Printf("%3s", "··")
@@ -148,11 +481,62 @@ func generateYearlyCountReport() {
         }
         lastDay = report.Day
     }
+    fmt.Print(strings.Repeat("   ", 31-lastDay))
 
     fmt.Println("║\n╚═══════════════════════════════════════════════════════════════════════════════════════════════════╝ ")
     fmt.Println()
 }
 
+// reportRange returns the --from and --to days, defaulting to the current week
+func reportRange(options reportOptions) (string, string) {
+    startOfWeek, endOfWeek := getWeek(options.now)
+    startOfWeek, endOfWeek = reportPeriod(options, startOfWeek, endOfWeek)
+    return formatDate(startOfWeek), formatDate(endOfWeek)
+}
+
+// generateProjectReport prints estimate vs actual pomodoros per project
+func generateProjectReport(options reportOptions) {
+    from, to := reportRange(options)
+    aggregates, err := getProjectBreakdown(db, from, to, options.project)
+    if err != nil {
+        log.Fatal(err)
+    }
+    if printRecords(aggregates) {
+        return
+    }
+
+    fmt.Println("╔════════════════════════════════════════════════════════════════════════════════════╗ ")
+    fmt.Printf( "║ %-82s ║\n", periodTitle("Project Report", from, to))
+    fmt.Println("╚════════════════════════════════════════════════════════════════════════════════════╝ ")
+    generateProjectBreakdown(from, to, options)
+}
+
+// generateTagReport prints the pomodoros spent per tag, for the current week
+// unless --from and --to say otherwise
+func generateTagReport(options reportOptions) {
+    from, to := reportRange(options)
+
+    aggregates, err := getTagReport(db, from, to, options.project)
+    if err != nil {
+        log.Fatal(err)
+    }
+    if printRecords(aggregates) {
+        return
+    }
+
+    fmt.Println("╔════════════════════════════════════════════════════════════════════════════════════╗ ")
+    fmt.Printf( "║ %-82s ║\n", periodTitle("Tag Report", from, to))
+    fmt.Println("╠════════════════════════════════════════════════════════════════════════════════════╣ ")
+    fmt.Printf( "║ %-30s   %-5s   %-41s ║\n", "Tag", "Tasks", "Pomodoros")
+    fmt.Println("╠════════════════════════════════════════════════════════════════════════════════════╣ ")
+    for _, aggregate := range aggregates {
+        width := min(aggregate.Pomodoros, 36)
+        fmt.Printf( "║ %-30s   %-5d   %-4d %s%s ║\n", "#"+aggregate.Tag, aggregate.Tasks, aggregate.Pomodoros,
+            colorize(strings.Repeat("▓", width), "32"), strings.Repeat(" ", 36-width))
+    }
+    fmt.Println("╚════════════════════════════════════════════════════════════════════════════════════╝ ")
+}
+
 // Function to generate report from the data
 func generateTaskReport(tasks []Task) {
     fmt.Printf("%-3s   %-46s   %-12s   %-12s\n", "ID", "Name", "Created", "Updated")
@@ -163,20 +547,67 @@ func generateTaskReport(tasks []Task) {
         actualTomatoes := generateEmojis(task.Actual, "🍅")
 
         fmt.Printf("%-3d   %-46s   %-12s   %-12s\n", task.ID, task.Name, formatDate(task.CreatedAt), formatDate(task.UpdatedAt))
-        fmt.Printf("      %s\n", task.Status)
+        details := []string{task.Status}
+        if task.Project != "" {
+            details = append(details, task.Project)
+        }
+        if len(task.Tags) > 0 {
+            details = append(details, formatTags(task.Tags))
+        }
+        if task.DueDate != "" {
+            details = append(details, "due "+task.DueDate)
+        }
+        fmt.Printf("      %s\n", strings.Join(details, " · "))
+        if task.Notes != "" {
+            fmt.Printf("      %s\n", task.Notes)
+        }
         fmt.Printf("      Estimate: %s Actual: %s\n", estimateSprouts, actualTomatoes)
+        if task.InternalInterruptions > 0 || task.ExternalInterruptions > 0 {
+            fmt.Printf("      Interruptions: %s %s\n", strings.Repeat("'", task.InternalInterruptions), strings.Repeat("-", task.ExternalInterruptions))
+        }
         fmt.Println(strings.Repeat("═", 80))
     }
 }
 
-func generateTodayReport() {
-    tasks, err := getDailyTasks(db)
+// getTodayTasks returns the tasks created today, or during the period given,
+// with their interruptions and tags, only those of a project when the options
+// name one
+func getTodayTasks(options reportOptions) ([]Task, error) {
+    from, to := todayRange(options)
+    tasks, err := getTasksCreatedBetween(db, from, to)
+    if err != nil {
+        return nil, err
+    }
+    if options.project != "" {
+        var projectTasks []Task
+        for _, task := range tasks {
+            if task.Project == options.project {
+                projectTasks = append(projectTasks, task)
+            }
+        }
+        tasks = projectTasks
+    }
+    if err := addInterruptionCounts(db, tasks); err != nil {
+        return nil, err
+    }
+    if err := addTaskTags(db, tasks); err != nil {
+        return nil, err
+    }
+    return tasks, nil
+}
+
+func generateTodayReport(options reportOptions) {
+    from, to := todayRange(options)
+    tasks, err := getTodayTasks(options)
     if err != nil {
         log.Fatal(err)
     }
+    if printRecords(tasks) {
+        return
+    }
 
     fmt.Println("╔════════════════════════════════════════════════════════════════════════════════════╗ ")
-	fmt.Printf( "║ %-3s   %-5s   %-54s   %-4s   %-4s ║\n", "ID", "Done?", "Task", "Est.", "Act.")
+	fmt.Printf( "║ %-3s   %-5s   %-40s   %-4s   %-4s   %-4s   %-4s ║\n", "ID", "Done?", "Task", "Est.", "Act.", "Int.", "Ext.")
 	fmt.Println("╠════════════════════════════════════════════════════════════════════════════════════╣ ")
 
     for _, task := range tasks {
@@ -192,17 +623,59 @@ func generateTodayReport() {
             status = "Yes"
             completedTasks++
         } 
-        fmt.Printf("║ %-3d   %-5s   %-54s   %-4d   %-4d ║\n", id, status, name, estimate, actual)
+        fmt.Printf("║ %-3d   %-5s   %-40s   %-4d   %-4d   %-4d   %-4d ║\n", id, status, name, estimate, actual, task.InternalInterruptions, task.ExternalInterruptions)
     }
     fmt.Println("╚════════════════════════════════════════════════════════════════════════════════════╝ ")
+
+    generateProjectBreakdown(from, to, options)
 }
 
+// todayRange returns the days of the today report: today unless a period is given
+func todayRange(options reportOptions) (string, string) {
+    today := startOfDay(options.now)
+    from, to := reportPeriod(options, today, today)
+    return formatDate(from), formatDate(to)
+}
 
-func listTasks(days int, status string) {
-    tasks, err := getTasks(days, status)
+// generateProjectBreakdown prints estimate vs actual pomodoros per project for the
+// tasks created between from and to
+func generateProjectBreakdown(from, to string, options reportOptions) {
+    aggregates, err := getProjectBreakdown(db, from, to, options.project)
     if err != nil {
         log.Fatal(err)
     }
+    if len(aggregates) == 0 {
+        return
+    }
+
+    fmt.Println("╔════════════════════════════════════════════════════════════════════════════════════╗ ")
+    fmt.Printf( "║ %-52s   %-5s   %-5s   %-4s   %-4s ║\n", "Project", "Tasks", "Done", "Est.", "Act.")
+    fmt.Println("╠════════════════════════════════════════════════════════════════════════════════════╣ ")
+    for _, aggregate := range aggregates {
+        name := aggregate.Project
+        if name == "" {
+            name = "(no project)"
+        }
+        fmt.Printf("║ %-52s   %-5d   %-5d   %-4d   %-4d ║\n", name, aggregate.Tasks, aggregate.Done, aggregate.Estimate, aggregate.Actual)
+    }
+    fmt.Println("╚════════════════════════════════════════════════════════════════════════════════════╝ ")
+}
+
+
+func listTasks(filter TaskFilter) {
+    tasks, err := getFilteredTasks(filter)
+    if err != nil {
+        log.Fatal(err)
+    }
+    if err := addInterruptionCounts(db, tasks); err != nil {
+        log.Fatal(err)
+    }
+    if err := addTaskTags(db, tasks); err != nil {
+        log.Fatal(err)
+    }
+    if printRecords(tasks) {
+        return
+    }
 
     generateTaskReport(tasks)
 }
\ No newline at end of file
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlotOccupancy(t *testing.T) {
+	day := time.Date(2024, time.September, 21, 0, 0, 0, 0, time.Local)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, time.September, 21, hour, minute, 0, 0, time.Local)
+	}
+
+	// a pomodoro from 10:20 to 10:45 takes a third of one slot and half the next
+	occupancy := slotOccupancy([]span{{at(10, 20), at(10, 45)}}, day, 48)
+	if occupancy[19] != 0 || occupancy[22] != 0 {
+		t.Errorf("expected the neighbouring slots to be free, got %v and %v", occupancy[19], occupancy[22])
+	}
+	if occupancy[20] < 0.33 || occupancy[20] > 0.34 || occupancy[21] != 0.5 {
+		t.Errorf("expected a third and a half, got %v and %v", occupancy[20], occupancy[21])
+	}
+
+	// a 50 minute block fills every slot it passes through
+	occupancy = slotOccupancy([]span{{at(14, 0), at(14, 50)}}, day, 48)
+	if occupancy[28] != 1 || occupancy[29] < 0.66 {
+		t.Errorf("expected a full and a mostly full slot, got %v and %v", occupancy[28], occupancy[29])
+	}
+
+	// a pomodoro started before midnight spills into the day
+	occupancy = slotOccupancy([]span{{at(0, -10), at(0, 15)}}, day, 48)
+	if occupancy[0] != 0.5 {
+		t.Errorf("expected half of the first slot, got %v", occupancy[0])
+	}
+
+	glyphs := occupancyGlyph(0.2) + occupancyGlyph(0.5) + occupancyGlyph(1)
+	if glyphs != "░▒▓" {
+		t.Errorf("expected the slots to be shaded by occupancy, got %q", glyphs)
+	}
+}
+
+func TestWriteDailyBlock(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	if err := addTask(db, "Task 1", 2, time.Now()); err != nil {
+		t.Fatalf("failed to add task: %v", err)
+	}
+	// a row tracked before timestamps were recorded counts as a full slot
+	if err := insertTrackingTask(1, "2024-09-21", 16); err != nil {
+		t.Fatalf("failed to track task: %v", err)
+	}
+	startedAt := time.Date(2024, time.September, 21, 10, 20, 0, 0, time.Local)
+	sessionID, err := beginPomodoro(1, 25*time.Minute, startedAt)
+	if err != nil {
+		t.Fatalf("failed to begin pomodoro: %v", err)
+	}
+	if _, err := completePomodoro(sessionID, 1, startedAt.Add(25*time.Minute)); err != nil {
+		t.Fatalf("failed to complete pomodoro: %v", err)
+	}
+
+	var out bytes.Buffer
+	writeDailyBlock(&out, "2024-09-21", reportOptions{})
+	hours := strings.Fields(strings.Trim(out.String(), "║ \n"))[1:]
+	if len(hours) != 24 {
+		t.Fatalf("expected 24 hours, got %d in %q", len(hours), out.String())
+	}
+	if hours[8] != colorize("▓", "32")+"·" {
+		t.Errorf("expected the legacy row to fill 08:00, got %q", hours[8])
+	}
+	if hours[10] != colorize("▒", "32")+colorize("▒", "32") {
+		t.Errorf("expected the pomodoro to shade both halves of 10:00, got %q", hours[10])
+	}
+}
+
+func TestRunningPomodoroSpans(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	for _, name := range []string{"Long pomodoro", "Activated"} {
+		if err := addTask(db, name, 2, time.Now()); err != nil {
+			t.Fatalf("failed to add task: %v", err)
+		}
+	}
+	// 'start -d 50m' still running, and an 'activate' that never got an 'update'
+	started := time.Date(2024, time.September, 21, 10, 0, 0, 0, time.Local)
+	activated := time.Date(2024, time.September, 21, 12, 0, 0, 0, time.Local)
+	if _, err := beginPomodoro(1, 50*time.Minute, started); err != nil {
+		t.Fatalf("failed to begin pomodoro: %v", err)
+	}
+	if _, err := beginPomodoro(2, defaultPomodoroDuration, activated); err != nil {
+		t.Fatalf("failed to begin pomodoro: %v", err)
+	}
+	rows, err := getTasksForDay("2024-09-21")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ends := func(now time.Time) map[int]time.Time {
+		spans := trackingSpans(rows, now)
+		found := map[int]time.Time{}
+		for i, row := range rows {
+			found[row.TaskID] = spans[i].end
+		}
+		return found
+	}
+	if end := ends(started.Add(40 * time.Minute))[1]; !end.Equal(started.Add(40 * time.Minute)) {
+		t.Errorf("expected the 50m pomodoro to be drawn up to now, got %v", end)
+	}
+	later := ends(activated.Add(3 * time.Hour))
+	if !later[1].Equal(started.Add(50 * time.Minute)) {
+		t.Errorf("expected the 50m pomodoro to be drawn for its planned 50m, got %v", later[1])
+	}
+	if !later[2].Equal(activated.Add(defaultPomodoroDuration)) {
+		t.Errorf("expected the activated pomodoro to be drawn for its planned 25m, got %v", later[2])
+	}
+}
+
+func TestBlockResolution(t *testing.T) {
+	for _, value := range []string{"15m", "30m", "1h"} {
+		if _, err := parseResolution(value); err != nil {
+			t.Errorf("expected %s to be a valid resolution: %v", value, err)
+		}
+	}
+	for _, value := range []string{"20m", "2h", "half"} {
+		if _, err := parseResolution(value); err == nil {
+			t.Errorf("expected %s to be rejected", value)
+		}
+	}
+
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	if err := addTask(db, "Task 1", 2, time.Now()); err != nil {
+		t.Fatalf("failed to add task: %v", err)
+	}
+	startedAt := time.Date(2024, time.September, 21, 10, 20, 0, 0, time.Local)
+	sessionID, err := beginPomodoro(1, 25*time.Minute, startedAt)
+	if err != nil {
+		t.Fatalf("failed to begin pomodoro: %v", err)
+	}
+	if _, err := completePomodoro(sessionID, 1, startedAt.Add(25*time.Minute)); err != nil {
+		t.Fatalf("failed to complete pomodoro: %v", err)
+	}
+
+	// quarter hours follow the timestamps, not the half hour the row was stored in
+	var out bytes.Buffer
+	writeDailyBlock(&out, "2024-09-21", reportOptions{resolution: 15 * time.Minute})
+	hours := strings.Fields(strings.Trim(out.String(), "║ \n"))[1:]
+	if len(hours) != 24 {
+		t.Fatalf("expected 24 hours, got %d in %q", len(hours), out.String())
+	}
+	green := func(glyph string) string { return colorize(glyph, "32") }
+	if want := "·" + green("▓") + green("▓") + "·"; hours[10] != want {
+		t.Errorf("expected 10:15 to 10:45 to be shaded, got %q", hours[10])
+	}
+
+	out.Reset()
+	writeDailyBlock(&out, "2024-09-21", reportOptions{resolution: time.Hour})
+	hours = strings.Fields(strings.Trim(out.String(), "║ \n"))[1:]
+	if len(hours) != 24 || hours[10] != green("▒") {
+		t.Errorf("expected a half full 10 o'clock, got %q", out.String())
+	}
+
+	if ruler := hourRuler(4); len(ruler) != 24*5 || !strings.HasPrefix(ruler, "00  |01  |") {
+		t.Errorf("unexpected quarter hour ruler %q", ruler)
+	}
+	if ruler := hourRuler(1); len(ruler) != 24*2 || !strings.HasPrefix(ruler, "00  02  ") {
+		t.Errorf("unexpected hourly ruler %q", ruler)
+	}
+}
+
+func TestResolveReportPeriod(t *testing.T) {
+	tests := []struct {
+		options           reportOptions
+		week, month, year string
+		from, to          string
+	}{
+		{reportOptions{}, "2026-W01", "", "", "2025-12-28", "2026-01-03"},
+		{reportOptions{}, "", "2026-09", "", "2026-09-01", "2026-09-30"},
+		{reportOptions{}, "", "", "2025", "2025-01-01", "2025-12-31"},
+		{reportOptions{from: "2025-12-15", to: "2026-01-15"}, "", "", "", "2025-12-15", "2026-01-15"},
+		{reportOptions{}, "", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		options := tt.options
+		if err := resolveReportPeriod(&options, tt.week, tt.month, tt.year); err != nil {
+			t.Errorf("unexpected error for %+v: %v", tt, err)
+			continue
+		}
+		if options.from != tt.from || options.to != tt.to {
+			t.Errorf("expected %s to %s, got %s to %s", tt.from, tt.to, options.from, options.to)
+		}
+	}
+
+	for _, options := range []reportOptions{{from: "yesterday"}, {from: "2026-02-01", to: "2026-01-01"}} {
+		if err := resolveReportPeriod(&options, "", "", ""); err == nil {
+			t.Errorf("expected %+v to be rejected", options)
+		}
+	}
+	options := reportOptions{from: "2026-01-01"}
+	if err := resolveReportPeriod(&options, "2026-W01", "", ""); err == nil {
+		t.Errorf("expected --from and --week together to be rejected")
+	}
+	if err := resolveReportPeriod(&reportOptions{}, "", "2026-01", "2026"); err == nil {
+		t.Errorf("expected --month and --year together to be rejected")
+	}
+}
+
+func TestYearlyRecordsAcrossYears(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	if err := addTask(db, "Task 1", 2, time.Now()); err != nil {
+		t.Fatalf("failed to add task: %v", err)
+	}
+	for _, date := range []string{"2024-12-31", "2025-01-01"} {
+		if err := insertTrackingTask(1, date, 20); err != nil {
+			t.Fatalf("failed to track task: %v", err)
+		}
+	}
+
+	options := reportOptions{from: "2024-12-01", to: "2025-01-31", now: time.Now()}
+	records, err := getYearlyRecords(db, options)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 31+31 {
+		t.Fatalf("expected every day from 2024-12-01 to 2025-01-31, got %d", len(records))
+	}
+	if first := records[0]; first.Year != 2024 || first.Month != time.December || first.Day != 1 {
+		t.Errorf("expected the period to start on 2024-12-01, got %+v", first)
+	}
+	if last := records[30]; last.Year != 2024 || last.Day != 31 || last.TaskCount != 1 {
+		t.Errorf("expected a pomodoro on 2024-12-31, got %+v", last)
+	}
+	if first := records[31]; first.Year != 2025 || first.Day != 1 || first.TaskCount != 1 {
+		t.Errorf("expected a pomodoro on 2025-01-01, got %+v", first)
+	}
+
+	start, end := reportPeriod(options, time.Time{}, time.Time{})
+	tracking, err := getTrackingForRange(start, end, "")
+	if err != nil || len(tracking) != 2 {
+		t.Errorf("expected both days across new year, got %v %+v", err, tracking)
+	}
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// apiSession is a pomodoro as the API returns it
+type apiSession struct {
+	ID               int       `json:"id"`
+	TaskID           int       `json:"task_id"`
+	Task             string    `json:"task"`
+	StartedAt        time.Time `json:"started_at"`
+	PlannedSeconds   int       `json:"planned_seconds"`
+	RemainingSeconds int       `json:"remaining_seconds"`
+	Outcome          string    `json:"outcome,omitempty"`
+}
+
+func newAPISession(session Session, now time.Time) apiSession {
+	return apiSession{
+		ID:               session.ID,
+		TaskID:           session.TaskID,
+		Task:             session.TaskName,
+		StartedAt:        session.StartedAt,
+		PlannedSeconds:   int(session.Planned.Seconds()),
+		RemainingSeconds: int(clampRemaining(session.Deadline().Sub(now)).Seconds()),
+		Outcome:          session.Outcome,
+	}
+}
+
+// apiServer answers the REST API of 'tomatillo serve'. Like the commands, the
+// helpers it calls use the global db as well.
+type apiServer struct {
+	db    *sql.DB
+	clock Clock
+
+	starting sync.Mutex // held while a pomodoro is started, so requests take turns
+}
+
+// newAPIHandler routes every endpoint of the API
+func newAPIHandler(db *sql.DB) http.Handler {
+	api := &apiServer{db: db, clock: clock}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tasks", api.listTasks)
+	mux.HandleFunc("POST /api/tasks", api.createTask)
+	mux.HandleFunc("GET /api/tasks/{id}", api.getTask)
+	mux.HandleFunc("PATCH /api/tasks/{id}", api.updateTask)
+	mux.HandleFunc("DELETE /api/tasks/{id}", api.deleteTask)
+	mux.HandleFunc("GET /api/pomodoros/current", api.currentPomodoro)
+	mux.HandleFunc("POST /api/pomodoros", api.startPomodoro)
+	mux.HandleFunc("POST /api/pomodoros/current/stop", api.stopPomodoro)
+	mux.HandleFunc("GET /api/reports/{type}", api.report)
+	return guardLocalRequests(mux)
+}
+
+// guardLocalRequests keeps other web pages away from the API and the dashboard,
+// which have no authentication. Requests must be addressed to a loopback host,
+// so a DNS rebinding page cannot read the responses, and come from the same
+// origin if from a browser at all. POST and PATCH must send JSON, which a page
+// on another origin cannot do without asking first.
+func guardLocalRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLoopbackHost(r.Host) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("host %q is not a loopback address", r.Host))
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || u.Host != r.Host {
+				writeError(w, http.StatusForbidden, fmt.Errorf("cross-origin requests are not allowed from %q", origin))
+				return
+			}
+		}
+		if r.Method == http.MethodPost || r.Method == http.MethodPatch {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != "application/json" {
+				writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("expected Content-Type: application/json"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackHost reports whether the Host of a request names this machine
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// writeJSON sends a value as the JSON body of the response
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("failed to write response: %v", err)
+	}
+}
+
+// writeError sends {"error": "..."} with the given status
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeList sends records as a JSON array, empty rather than null
+func writeList(w http.ResponseWriter, records interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := writeRecords(w, "json", records); err != nil {
+		log.Printf("failed to write response: %v", err)
+	}
+}
+
+// taskID reads the {id} of the request path
+func taskID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid task ID %q", r.PathValue("id")))
+		return 0, false
+	}
+	return id, true
+}
+
+func (api *apiServer) listTasks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := TaskFilter{Days: 7, Now: api.clock.Now(), Status: "all", Project: query.Get("project"), Tag: query.Get("tag")}
+	if days := query.Get("days"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid days %q", days))
+			return
+		}
+		filter.Days = n
+	}
+	if status := query.Get("status"); status != "" {
+		filter.Status = status
+	}
+
+	tasks, err := getFilteredTasks(filter)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := addInterruptionCounts(api.db, tasks); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := addTaskTags(api.db, tasks); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeList(w, tasks)
+}
+
+func (api *apiServer) createTask(w http.ResponseWriter, r *http.Request) {
+	var task Task
+	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid task: %v", err))
+		return
+	}
+	if task.DueDate != "" {
+		if _, err := time.Parse("2006-01-02", task.DueDate); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid due date %q, expected YYYY-MM-DD", task.DueDate))
+			return
+		}
+	}
+
+	// only the fields a new task can have are taken from the request
+	id, err := createTask(api.db, Task{Name: task.Name, Estimate: task.Estimate, Project: task.Project, Tags: task.Tags,
+		DueDate: task.DueDate, Notes: task.Notes}, api.clock.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	created, err := getTask(api.db, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (api *apiServer) getTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := taskID(w, r)
+	if !ok {
+		return
+	}
+	task, err := getTask(api.db, id)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no task found with ID: %d", id))
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, task)
+}
+
+func (api *apiServer) updateTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := taskID(w, r)
+	if !ok {
+		return
+	}
+	var changes TaskChanges
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid changes: %v", err))
+		return
+	}
+
+	found, err := changeTask(api.db, id, changes, api.clock.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no task found with ID: %d", id))
+		return
+	}
+	api.getTask(w, r)
+}
+
+func (api *apiServer) deleteTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := taskID(w, r)
+	if !ok {
+		return
+	}
+	deleted, err := removeTask(api.db, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !deleted {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no task found with ID: %d", id))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *apiServer) currentPomodoro(w http.ResponseWriter, r *http.Request) {
+	session, err := getRunningSession(api.db)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, errors.New("no pomodoro is running"))
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPISession(session, api.clock.Now()))
+}
+
+// startPomodoro opens a session and completes it when its time is up, so the
+// client does not have to stay connected. If the server stops first, 'stop'
+// settles the session like any other.
+func (api *apiServer) startPomodoro(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		TaskID   int    `json:"task_id"`
+		Duration string `json:"duration"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid pomodoro: %v", err))
+		return
+	}
+	duration := defaultPomodoroDuration
+	if request.Duration != "" {
+		d, err := time.ParseDuration(request.Duration)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid duration %q", request.Duration))
+			return
+		}
+		duration = d
+	}
+
+	exists, err := taskIDExists(api.db, request.TaskID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no task found with ID: %d", request.TaskID))
+		return
+	}
+
+	// the session is only added when none is open, the lock keeps concurrent
+	// requests from running into a busy database instead
+	api.starting.Lock()
+	defer api.starting.Unlock()
+	now := api.clock.Now()
+	sessionID, err := beginOnlyPomodoro(request.TaskID, duration, now)
+	if err == errPomodoroRunning {
+		if running, err := getRunningSession(api.db); err == nil {
+			writeError(w, http.StatusConflict, fmt.Errorf("a pomodoro is already running on task %d", running.TaskID))
+			return
+		}
+		writeError(w, http.StatusConflict, err)
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	time.AfterFunc(duration, func() {
+		if _, err := completePomodoro(sessionID, request.TaskID, api.clock.Now()); err != nil {
+			log.Printf("failed to complete pomodoro %d: %v", sessionID, err)
+		}
+	})
+
+	session, err := getRunningSession(api.db)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, newAPISession(session, now))
+}
+
+func (api *apiServer) stopPomodoro(w http.ResponseWriter, r *http.Request) {
+	session, err := getRunningSession(api.db)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, errors.New("no pomodoro is running"))
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	now := api.clock.Now()
+	session.Outcome, err = stopPomodoro(session, now)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPISession(session, now))
+}
+
+// report returns the records behind each 'report --type', the same ones
+// --format json prints
+func (api *apiServer) report(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	options := reportOptions{project: query.Get("project"), from: query.Get("from"), to: query.Get("to"), now: api.clock.Now().Local()}
+	if err := resolveReportPeriod(&options, query.Get("week"), query.Get("month"), query.Get("year")); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var records interface{}
+	var err error
+	switch r.PathValue("type") {
+	case "today":
+		records, err = getTodayTasks(options)
+	case "blockweek", "blockmonth":
+		start, end := getWeek(options.now)
+		if r.PathValue("type") == "blockmonth" {
+			start, end = getMonth(options.now)
+		}
+		start, end = reportPeriod(options, start, end)
+		records, err = getTrackingForRange(start, end, options.project)
+	case "yearly":
+		records, err = getYearlyRecords(api.db, options)
+	case "tags":
+		from, to := reportRange(options)
+		records, err = getTagReport(api.db, from, to, options.project)
+	case "projects":
+		from, to := reportRange(options)
+		records, err = getProjectBreakdown(api.db, from, to, options.project)
+	case "accuracy":
+		from, to := accuracyRange(options)
+		records, err = getTaskAccuracy(api.db, from, to, options.project)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown report type %q", r.PathValue("type")))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeList(w, records)
+}
+
+// Helper function to handle the 'serve' command. The API has no authentication,
+// so it listens on the loopback interface and only answers requests addressed to it.
+func handleServeCommand(args []string) {
+	serveFlag := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := serveFlag.String("addr", "127.0.0.1:7878", "Address to listen on")
+	serveFlag.Parse(args)
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           newAPIHandler(db),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	log.Printf("Serving the tomatillo API on http://%s/api", *addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// apiRequest sends a request to the API and decodes the JSON response into out
+func apiRequest(t *testing.T, handler http.Handler, method, path, body string, out interface{}) int {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Host = "127.0.0.1:7878"
+	if method == "POST" || method == "PATCH" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	if out != nil {
+		if err := json.Unmarshal(recorder.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s returned invalid JSON %q: %v", method, path, recorder.Body.String(), err)
+		}
+	}
+	return recorder.Code
+}
+
+func TestAPITasks(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+	handler := newAPIHandler(db)
+
+	var task Task
+	status := apiRequest(t, handler, "POST", "/api/tasks", `{"name": "Fix login", "estimate": 2, "tags": ["bug"], "due_date": "2024-10-01"}`, &task)
+	if status != http.StatusCreated || task.ID != 1 || task.Name != "Fix login" || task.DueDate != "2024-10-01" || len(task.Tags) != 1 {
+		t.Fatalf("unexpected response to creating a task: %d %+v", status, task)
+	}
+	if status := apiRequest(t, handler, "POST", "/api/tasks", `{"estimate": 2}`, nil); status != http.StatusBadRequest {
+		t.Errorf("expected a task without a name to be rejected, got %d", status)
+	}
+
+	var tasks []Task
+	if status := apiRequest(t, handler, "GET", "/api/tasks?status=todo", "", &tasks); status != http.StatusOK || len(tasks) != 1 {
+		t.Errorf("expected 1 task to do, got %d %+v", status, tasks)
+	}
+
+	status = apiRequest(t, handler, "PATCH", "/api/tasks/1", `{"estimate": 4, "done": true}`, &task)
+	if status != http.StatusOK || task.Estimate != 4 || !task.Done || task.Name != "Fix login" {
+		t.Errorf("unexpected response to updating a task: %d %+v", status, task)
+	}
+
+	if status := apiRequest(t, handler, "DELETE", "/api/tasks/1", "", nil); status != http.StatusNoContent {
+		t.Errorf("expected the task to be deleted, got %d", status)
+	}
+	var apiErr map[string]string
+	if status := apiRequest(t, handler, "GET", "/api/tasks/1", "", &apiErr); status != http.StatusNotFound || apiErr["error"] == "" {
+		t.Errorf("expected a deleted task not to be found, got %d %v", status, apiErr)
+	}
+	if status := apiRequest(t, handler, "GET", "/api/tasks/abc", "", nil); status != http.StatusBadRequest {
+		t.Errorf("expected an invalid ID to be rejected, got %d", status)
+	}
+}
+
+func TestAPIPomodoros(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+	handler := newAPIHandler(db)
+
+	if _, err := createTask(db, Task{Name: "Fix login", Estimate: 2}, time.Now()); err != nil {
+		t.Fatalf("failed to add task: %v", err)
+	}
+
+	if status := apiRequest(t, handler, "GET", "/api/pomodoros/current", "", nil); status != http.StatusNotFound {
+		t.Errorf("expected no running pomodoro, got %d", status)
+	}
+
+	var session apiSession
+	status := apiRequest(t, handler, "POST", "/api/pomodoros", `{"task_id": 1, "duration": "25m"}`, &session)
+	if status != http.StatusCreated || session.TaskID != 1 || session.Task != "Fix login" || session.PlannedSeconds != 1500 {
+		t.Fatalf("unexpected response to starting a pomodoro: %d %+v", status, session)
+	}
+	if status := apiRequest(t, handler, "POST", "/api/pomodoros", `{"task_id": 1}`, nil); status != http.StatusConflict {
+		t.Errorf("expected a second pomodoro to be refused, got %d", status)
+	}
+	if status := apiRequest(t, handler, "GET", "/api/pomodoros/current", "", &session); status != http.StatusOK || session.RemainingSeconds <= 0 {
+		t.Errorf("expected the running pomodoro, got %d %+v", status, session)
+	}
+
+	status = apiRequest(t, handler, "POST", "/api/pomodoros/current/stop", "", &session)
+	if status != http.StatusOK || session.Outcome != "abandoned" {
+		t.Errorf("expected the pomodoro stopped early to be abandoned, got %d %+v", status, session)
+	}
+}
+
+func TestAPIConcurrentPomodoros(t *testing.T) {
+	// a file, so the requests get connections of their own
+	db = initializeDatabase(filepath.Join(t.TempDir(), "tomatillo.db"))
+	defer db.Close()
+	handler := newAPIHandler(db)
+
+	if _, err := createTask(db, Task{Name: "Fix login", Estimate: 2}, time.Now()); err != nil {
+		t.Fatalf("failed to add task: %v", err)
+	}
+
+	statuses := make([]int, 10)
+	var wg sync.WaitGroup
+	for i := range statuses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			statuses[i] = apiRequest(t, handler, "POST", "/api/pomodoros", `{"task_id": 1}`, nil)
+		}(i)
+	}
+	wg.Wait()
+
+	started := 0
+	for _, status := range statuses {
+		switch status {
+		case http.StatusCreated:
+			started++
+		case http.StatusConflict:
+		default:
+			t.Errorf("expected 201 or 409, got %d", status)
+		}
+	}
+	var sessions int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sessions`).Scan(&sessions); err != nil {
+		t.Fatalf("failed to count sessions: %v", err)
+	}
+	if started != 1 || sessions != 1 {
+		t.Errorf("expected a single pomodoro, got %d started and %d sessions", started, sessions)
+	}
+}
+
+func TestStartOnlySession(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	if _, err := createTask(db, Task{Name: "Fix login", Estimate: 2}, time.Now()); err != nil {
+		t.Fatalf("failed to add task: %v", err)
+	}
+	if _, err := beginOnlyPomodoro(1, defaultPomodoroDuration, time.Now()); err != nil {
+		t.Fatalf("failed to begin pomodoro: %v", err)
+	}
+	if _, err := beginOnlyPomodoro(1, defaultPomodoroDuration, time.Now()); err != errPomodoroRunning {
+		t.Errorf("expected errPomodoroRunning, got %v", err)
+	}
+}
+
+func TestAPIReports(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+	handler := newAPIHandler(db)
+
+	if _, err := createTask(db, Task{Name: "Fix login", Estimate: 2, Tags: []string{"bug"}}, time.Now()); err != nil {
+		t.Fatalf("failed to add task: %v", err)
+	}
+	if err := insertTrackingTask(1, "2024-09-21", 20); err != nil {
+		t.Fatalf("failed to track task: %v", err)
+	}
+
+	var tracking []TaskTracking
+	status := apiRequest(t, handler, "GET", "/api/reports/blockweek?from=2024-09-15&to=2024-09-21", "", &tracking)
+	if status != http.StatusOK || len(tracking) != 1 || tracking[0].Date != "2024-09-21" {
+		t.Errorf("expected the tracked half hour, got %d %+v", status, tracking)
+	}
+
+	var tags []TagAggregate
+	status = apiRequest(t, handler, "GET", "/api/reports/tags?from=2024-09-01&to=2024-09-30", "", &tags)
+	if status != http.StatusOK || len(tags) != 1 || tags[0].Tag != "bug" || tags[0].Pomodoros != 1 {
+		t.Errorf("expected 1 pomodoro tagged bug, got %d %+v", status, tags)
+	}
+
+	var yearly []TaskTrackingAggregate
+	if status := apiRequest(t, handler, "GET", "/api/reports/yearly?year=2024", "", &yearly); status != http.StatusOK || len(yearly) != 366 {
+		t.Errorf("expected a row for every day of 2024, got %d with %d rows", status, len(yearly))
+	}
+
+	if status := apiRequest(t, handler, "GET", "/api/reports/weather", "", nil); status != http.StatusNotFound {
+		t.Errorf("expected an unknown report to be rejected, got %d", status)
+	}
+	if status := apiRequest(t, handler, "GET", "/api/reports/tags?from=yesterday", "", nil); status != http.StatusBadRequest {
+		t.Errorf("expected an invalid date to be rejected, got %d", status)
+	}
+
+	// a failing database is an error response, not the end of the server
+	db.Close()
+	if status := apiRequest(t, handler, "GET", "/api/reports/today", "", nil); status != http.StatusInternalServerError {
+		t.Errorf("expected a database error to be a 500, got %d", status)
+	}
+}
+
+func TestAPIRejectsOtherSites(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+	handler := newAPIHandler(db)
+
+	tests := []struct {
+		name        string
+		method      string
+		host        string
+		origin      string
+		contentType string
+		want        int
+	}{
+		{"same origin", "POST", "127.0.0.1:7878", "http://127.0.0.1:7878", "application/json", http.StatusCreated},
+		{"no origin", "POST", "localhost:7878", "", "application/json; charset=utf-8", http.StatusCreated},
+		{"IPv6 loopback", "GET", "[::1]:7878", "", "", http.StatusOK},
+		{"rebound host", "GET", "attacker.example:7878", "", "", http.StatusForbidden},
+		{"LAN host", "POST", "192.168.1.20:7878", "", "application/json", http.StatusForbidden},
+		{"cross origin", "POST", "127.0.0.1:7878", "https://attacker.example", "application/json", http.StatusForbidden},
+		{"other port", "GET", "127.0.0.1:7878", "http://127.0.0.1:3000", "", http.StatusForbidden},
+		{"null origin", "POST", "127.0.0.1:7878", "null", "application/json", http.StatusForbidden},
+		{"form post", "POST", "127.0.0.1:7878", "", "text/plain", http.StatusUnsupportedMediaType},
+		{"no content type", "POST", "127.0.0.1:7878", "", "", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		path, body := "/api/tasks", ""
+		if tt.method == "POST" {
+			body = `{"name": "Fix login", "estimate": 1}`
+		}
+		request := httptest.NewRequest(tt.method, path, strings.NewReader(body))
+		request.Host = tt.host
+		if tt.origin != "" {
+			request.Header.Set("Origin", tt.origin)
+		}
+		if tt.contentType != "" {
+			request.Header.Set("Content-Type", tt.contentType)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d %s", tt.name, tt.want, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	// only the two allowed POSTs added a task
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM tasks`).Scan(&count); err != nil {
+		t.Fatalf("failed to count tasks: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 tasks, got %d", count)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TagAggregate sums up the pomodoros spent on the tasks carrying a tag
+type TagAggregate struct {
+	Tag       string `json:"tag"`
+	Tasks     int    `json:"tasks"`
+	Pomodoros int    `json:"pomodoros"`
+}
+
+// tagList collects a repeatable --tag flag
+type tagList []string
+
+func (t *tagList) String() string {
+	return strings.Join(*t, ",")
+}
+
+func (t *tagList) Set(value string) error {
+	tag := normalizeTag(value)
+	if tag == "" {
+		return fmt.Errorf("tag cannot be empty")
+	}
+	*t = append(*t, tag)
+	return nil
+}
+
+// normalizeTag makes "#Bug " and "bug" the same tag
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+// formatTags renders tags the way they are typed on the command line
+func formatTags(tags []string) string {
+	formatted := make([]string, len(tags))
+	for i, tag := range tags {
+		formatted[i] = "#" + tag
+	}
+	return strings.Join(formatted, " ")
+}
+
+// tagTask attaches tags to a task, creating tags that do not exist yet
+func tagTask(db execer, taskID int, tags []string) error {
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag == "" {
+			continue
+		}
+
+		_, err := db.Exec(`INSERT INTO tags (name) VALUES (?) ON CONFLICT(name) DO NOTHING`, tag)
+		if err != nil {
+			return fmt.Errorf("failed to add tag %s: %v", tag, err)
+		}
+
+		_, err = db.Exec(`
+    INSERT INTO task_tags (task_id, tag_id)
+    SELECT ?, id FROM tags WHERE name = ?
+    ON CONFLICT(task_id, tag_id) DO NOTHING`, taskID, tag)
+		if err != nil {
+			return fmt.Errorf("failed to tag task: %v", err)
+		}
+	}
+	return nil
+}
+
+// addTaskTags fills in the tags of each task
+func addTaskTags(db *sql.DB, tasks []Task) error {
+	rows, err := db.Query(`
+    SELECT tt.task_id, g.name FROM task_tags tt
+    JOIN tags g ON g.id = tt.tag_id
+    ORDER BY g.name`)
+	if err != nil {
+		return fmt.Errorf("failed to load tags: %v", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[int][]string)
+	for rows.Next() {
+		var taskID int
+		var tag string
+		if err := rows.Scan(&taskID, &tag); err != nil {
+			return err
+		}
+		tags[taskID] = append(tags[taskID], tag)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range tasks {
+		tasks[i].Tags = append([]string{}, tags[tasks[i].ID]...)
+	}
+	return nil
+}
+
+// getTagReport counts the half hours tracked per tag between from and to
+// (inclusive, formatted 2006-01-02), busiest tag first
+func getTagReport(db *sql.DB, from, to string, project string) ([]TagAggregate, error) {
+	query := `
+    SELECT g.name, COUNT(DISTINCT tr.task_id), COUNT(tr.id)
+    FROM task_tracking tr
+    JOIN tasks t ON t.id = tr.task_id
+    LEFT JOIN projects p ON p.id = t.project_id
+    JOIN task_tags tt ON tt.task_id = t.id
+    JOIN tags g ON g.id = tt.tag_id
+    WHERE tr.date BETWEEN ? AND ?
+    AND (? = '' OR p.name = ?)
+    GROUP BY g.name`
+
+	rows, err := db.Query(query, from, to, project, project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize tags: %v", err)
+	}
+	defer rows.Close()
+
+	var aggregates []TagAggregate
+	for rows.Next() {
+		var aggregate TagAggregate
+		if err := rows.Scan(&aggregate.Tag, &aggregate.Tasks, &aggregate.Pomodoros); err != nil {
+			return nil, err
+		}
+		aggregates = append(aggregates, aggregate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(aggregates, func(i, j int) bool {
+		if aggregates[i].Pomodoros != aggregates[j].Pomodoros {
+			return aggregates[i].Pomodoros > aggregates[j].Pomodoros
+		}
+		return aggregates[i].Tag < aggregates[j].Tag
+	})
+	return aggregates, nil
+}
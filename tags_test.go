@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTagList(t *testing.T) {
+	var tags tagList
+	for _, value := range []string{"bug", "#Auth ", " ui"} {
+		if err := tags.Set(value); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if tags.String() != "bug,auth,ui" {
+		t.Errorf("expected normalized tags 'bug,auth,ui', got %q", tags.String())
+	}
+	if err := tags.Set("#"); err == nil {
+		t.Error("expected an error for an empty tag, but got none")
+	}
+}
+
+func TestTags(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	tasks := []Task{
+		{Name: "Fix login", Estimate: 2, Tags: []string{"bug", "auth"}},
+		{Name: "Fix layout", Estimate: 1, Tags: []string{"bug"}},
+		{Name: "Write docs", Estimate: 1},
+	}
+	for _, task := range tasks {
+		if _, err := createTask(db, task, time.Now()); err != nil {
+			t.Fatalf("failed to add task: %v", err)
+		}
+	}
+
+	bugs, err := getFilteredTasks(TaskFilter{Days: 1, Now: time.Now(), Status: "all", Tag: "#BUG"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bugs) != 2 {
+		t.Errorf("expected 2 tasks tagged bug, got %d", len(bugs))
+	}
+
+	if err := addTaskTags(db, bugs); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bugs[0].Tags) != 2 || bugs[0].Tags[0] != "auth" || bugs[0].Tags[1] != "bug" {
+		t.Errorf("expected the first task to be tagged auth and bug, got %v", bugs[0].Tags)
+	}
+
+	for _, tracking := range []struct{ id, halfHour int }{{1, 20}, {1, 21}, {2, 22}, {3, 23}} {
+		if err := insertTrackingTask(tracking.id, "2024-09-21", tracking.halfHour); err != nil {
+			t.Fatalf("failed to track task: %v", err)
+		}
+	}
+	if err := insertTrackingTask(1, "2024-09-30", 20); err != nil {
+		t.Fatalf("failed to track task: %v", err)
+	}
+
+	report, err := getTagReport(db, "2024-09-15", "2024-09-21", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report) != 2 {
+		t.Fatalf("expected 2 tags in the report, got %d", len(report))
+	}
+	if report[0].Tag != "bug" || report[0].Pomodoros != 3 || report[0].Tasks != 2 {
+		t.Errorf("unexpected bug row: %+v", report[0])
+	}
+	if report[1].Tag != "auth" || report[1].Pomodoros != 2 {
+		t.Errorf("unexpected auth row: %+v", report[1])
+	}
+}
+
+func TestCreateTaskWithFailingTags(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	_, err := db.Exec(`
+    CREATE TRIGGER refuse_tags BEFORE INSERT ON task_tags
+    BEGIN SELECT RAISE(ABORT, 'tagging refused'); END;`)
+	if err != nil {
+		t.Fatalf("failed to create trigger: %v", err)
+	}
+
+	if _, err := createTask(db, Task{Name: "Fix login", Estimate: 2, Tags: []string{"bug"}}, time.Now()); err == nil {
+		t.Fatal("expected tagging to fail")
+	}
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM tasks`).Scan(&count); err != nil {
+		t.Fatalf("failed to count tasks: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("expected the task to be rolled back with its tags, got %d tasks", count)
+	}
+}
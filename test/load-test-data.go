@@ -39,8 +39,8 @@ func main() {
         // Insert the task into the database
         _, err := db.Exec(`INSERT INTO tasks (name, estimate, actual, created_at, updated_at, done) 
                            VALUES (?, ?, ?, ?, ?, ?)`,
-            name, estimate, actual, createdAt.Format("2006-01-02 15:04:05"),
-            updatedAt.Format("2006-01-02 15:04:05"), done)
+            name, estimate, actual, createdAt.UTC().Format("2006-01-02 15:04:05"),
+            updatedAt.UTC().Format("2006-01-02 15:04:05"), done)
         if err != nil {
             log.Fatal(err)
         }
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const defaultPomodoroDuration = 25 * time.Minute
+
+// Helper function to handle the 'start' command. It runs the countdown in-process,
+// tracks the task when the pomodoro starts and only counts it once the timer completes.
+// Without --id it is a plain timer that records nothing.
+func handleStartCommand(args []string) {
+	startFlag := flag.NewFlagSet("start", flag.ExitOnError)
+	taskId := startFlag.Int("id", 0, "Task ID to work on, none for a plain timer")
+	duration := startFlag.Duration("duration", defaultPomodoroDuration, "Length of the pomodoro, e.g. 25m (or use -d)")
+	startFlag.DurationVar(duration, "d", defaultPomodoroDuration, "Length of the pomodoro (short version)")
+	startFlag.Parse(args)
+
+	if *taskId < 0 {
+		log.Println("Please provide a valid task ID.")
+		os.Exit(1)
+	}
+	if *duration <= 0 {
+		log.Println("Please provide a positive duration.")
+		os.Exit(1)
+	}
+	if *taskId == 0 {
+		fmt.Printf("Starting a %s pomodoro without a task. Press Ctrl-C to give up.\n", formatCountdown(*duration))
+		runUntrackedPomodoro(*duration)
+		return
+	}
+	requireTask(*taskId)
+
+	if running, err := getRunningSession(db); err == nil {
+		log.Printf("A pomodoro is already running on task %d, use 'tomatillo resume' or 'tomatillo stop'.\n", running.TaskID)
+		os.Exit(1)
+	} else if err != sql.ErrNoRows {
+		log.Fatal(err)
+	}
+
+	now := clock.Now()
+	sessionId, err := beginPomodoro(*taskId, *duration, now)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Starting a %s pomodoro on task %d. Press Ctrl-C to give up.\n", formatCountdown(*duration), *taskId)
+	runPomodoro(sessionId, *taskId, now.Add(*duration))
+}
+
+// Helper function to handle the 'status' command
+func handleStatusCommand(args []string) {
+	statusFlag := flag.NewFlagSet("status", flag.ExitOnError)
+	statusFlag.Parse(args)
+
+	now := clock.Now()
+	session, err := getRunningSession(db)
+	switch {
+	case err == sql.ErrNoRows:
+		fmt.Println("No pomodoro is running.")
+	case err != nil:
+		log.Fatal(err)
+	case now.Before(session.Deadline()):
+		fmt.Printf("🍅 %s left on task %d: %s (started at %s)\n", formatCountdown(session.Deadline().Sub(now)),
+			session.TaskID, session.TaskName, session.StartedAt.Local().Format("15:04"))
+	default:
+		fmt.Printf("🍅 The pomodoro on task %d: %s was due at %s, run 'tomatillo stop' to count it.\n",
+			session.TaskID, session.TaskName, session.Deadline().Local().Format("15:04"))
+	}
+
+	if b, err := getRunningBreak(db); err == nil {
+		deadline := b.StartedAt.Add(b.Planned)
+		if now.Before(deadline) {
+			fmt.Printf("☕ %s left on a %s break\n", formatCountdown(deadline.Sub(now)), b.Kind)
+		}
+	} else if err != sql.ErrNoRows {
+		log.Fatal(err)
+	}
+}
+
+// Helper function to handle the 'resume' command. It picks up the countdown of a
+// pomodoro whose terminal was closed, from any shell.
+func handleResumeCommand(args []string) {
+	resumeFlag := flag.NewFlagSet("resume", flag.ExitOnError)
+	resumeFlag.Parse(args)
+
+	session, err := getRunningSession(db)
+	if err == sql.ErrNoRows {
+		log.Println("No pomodoro is running.")
+		os.Exit(1)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Resuming the pomodoro on task %d: %s. Press Ctrl-C to give up.\n", session.TaskID, session.TaskName)
+	runPomodoro(session.ID, session.TaskID, session.Deadline())
+}
+
+// Helper function to handle the 'stop' command. A pomodoro that has run its full
+// length is counted, one that is stopped early is abandoned.
+func handleStopCommand(args []string) {
+	stopFlag := flag.NewFlagSet("stop", flag.ExitOnError)
+	stopFlag.Parse(args)
+
+	session, err := getRunningSession(db)
+	if err == sql.ErrNoRows {
+		log.Println("No pomodoro is running.")
+		os.Exit(1)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	outcome, err := stopPomodoro(session, clock.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if outcome == "abandoned" {
+		fmt.Printf("Pomodoro on task %d abandoned, the actual count was not updated.\n", session.TaskID)
+	}
+}
+
+// stopPomodoro ends a running session. It is counted when it has run its full
+// length and abandoned otherwise; the outcome is returned.
+func stopPomodoro(session Session, now time.Time) (string, error) {
+	if now.Before(session.Deadline()) {
+		_, err := endSession(db, session.ID, now, "abandoned", "", "")
+		return "abandoned", err
+	}
+	_, err := completePomodoro(session.ID, session.TaskID, session.Deadline())
+	return "completed", err
+}
+
+// runPomodoro counts down to the deadline of a session and records how it ended.
+// Ctrl-C abandons the pomodoro, closing the terminal leaves it running so that
+// 'tomatillo resume' or 'tomatillo stop' can pick it up from another shell.
+func runPomodoro(sessionID, taskID int, deadline time.Time) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	switch runTimer(os.Stdout, "🍅", deadline.Sub(clock.Now()), signals) {
+	case nil:
+	case syscall.SIGHUP:
+		return
+	default:
+		if _, err := endSession(db, sessionID, clock.Now(), "abandoned", "", ""); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Pomodoro abandoned, the actual count was not updated.")
+		return
+	}
+
+	fmt.Print("\a")
+	completed, err := completePomodoro(sessionID, taskID, clock.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !completed {
+		fmt.Println("Pomodoro was interrupted, the actual count was not updated.")
+	}
+}
+
+// runUntrackedPomodoro counts down a pomodoro that is not on any task, so
+// nothing is tracked or counted
+func runUntrackedPomodoro(duration time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	if runTimer(os.Stdout, "🍅", duration, signals) != nil {
+		fmt.Println("Pomodoro abandoned.")
+		return
+	}
+	fmt.Print("\a")
+}
+
+// beginPomodoro tracks the task in the current half hour and opens its session
+func beginPomodoro(taskID int, duration time.Duration, now time.Time) (int, error) {
+	if err := startTracking(db, taskID, now); err != nil {
+		return 0, err
+	}
+	return startSession(db, taskID, duration, now)
+}
+
+// beginOnlyPomodoro is beginPomodoro for when no other pomodoro may run: it
+// returns errPomodoroRunning and adds nothing when a session is already open.
+// The session and the tracking are added in one transaction.
+func beginOnlyPomodoro(taskID int, duration time.Duration, now time.Time) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin pomodoro: %v", err)
+	}
+	defer tx.Rollback()
+
+	id, err := startOnlySession(tx, taskID, duration, now)
+	if err != nil {
+		return 0, err
+	}
+	if err := startTracking(tx, taskID, now); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit pomodoro: %v", err)
+	}
+	return id, nil
+}
+
+// completePomodoro closes the session and counts it towards the actual of the
+// task, in one transaction so a counted pomodoro never stays open. It reports
+// false when the session was already interrupted.
+func completePomodoro(sessionID, taskID int, now time.Time) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin completing pomodoro: %v", err)
+	}
+	defer tx.Rollback()
+
+	completed, err := endSession(tx, sessionID, now, "completed", "", "")
+	if err != nil || !completed {
+		return false, err
+	}
+	if err := updateActual(tx, taskID, now); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit pomodoro: %v", err)
+	}
+	return true, nil
+}
+
+// Helper function to handle the 'break' command. Without --short or --long the
+// kind of break follows the configured cycle: a long break after every 'cycle'
+// pomodoros completed today, a short one otherwise.
+func handleBreakCommand(args []string) {
+	breakFlag := flag.NewFlagSet("break", flag.ExitOnError)
+	short := breakFlag.Bool("short", false, "Take a short break")
+	long := breakFlag.Bool("long", false, "Take a long break")
+	duration := breakFlag.Duration("duration", 0, "Length of the break, defaults to the configured short_break or long_break (or use -d)")
+	breakFlag.DurationVar(duration, "d", 0, "Length of the break (short version)")
+	breakFlag.Parse(args)
+
+	if *short && *long {
+		log.Println("Please choose either --short or --long.")
+		os.Exit(1)
+	}
+
+	now := clock.Now()
+	kind, err := nextBreakKind(now, *short, *long)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *duration <= 0 {
+		*duration, err = getDurationConfig(db, kind+"_break")
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	breakId, err := startBreak(db, kind, *duration, now)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	fmt.Printf("Taking a %s %s break.\n", formatCountdown(*duration), kind)
+	completed := runTimer(os.Stdout, "☕", *duration, interrupt) == nil
+	if err := endBreak(db, breakId, clock.Now()); err != nil {
+		log.Fatal(err)
+	}
+	if completed {
+		fmt.Print("\a")
+		fmt.Println("Break is over, back to work.")
+	} else {
+		fmt.Println("Break cut short.")
+	}
+}
+
+// nextBreakKind decides between a short and a long break
+func nextBreakKind(now time.Time, short, long bool) (string, error) {
+	if short {
+		return "short", nil
+	}
+	if long {
+		return "long", nil
+	}
+
+	cycle, err := getIntConfig(db, "cycle")
+	if err != nil {
+		return "", err
+	}
+	count, err := countPomodorosSinceLongBreak(db, now.Format("2006-01-02"))
+	if err != nil {
+		return "", err
+	}
+	if count >= cycle {
+		return "long", nil
+	}
+	return "short", nil
+}
+
+// runTimer counts down the given duration, redrawing the remaining time every second.
+// It returns nil when the countdown completes and the signal that stopped it otherwise.
+func runTimer(w io.Writer, icon string, duration time.Duration, stop <-chan os.Signal) os.Signal {
+	// a countdown measures elapsed time, which the wall clock tells best
+	deadline := time.Now().Add(duration)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			fmt.Fprintf(w, "\r%s %s\n", icon, formatCountdown(0))
+			return nil
+		}
+		fmt.Fprintf(w, "\r%s %s ", icon, formatCountdown(remaining))
+
+		select {
+		case sig := <-stop:
+			fmt.Fprintln(w)
+			return sig
+		case <-ticker.C:
+		case <-time.After(remaining):
+		}
+	}
+}
+
+// formatCountdown renders a duration as mm:ss, rounding partial seconds up
+// so the display never reads 00:00 while time is still left.
+func formatCountdown(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	seconds := int((d + time.Second - 1) / time.Second)
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatCountdown(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		expected string
+	}{
+		{25 * time.Minute, "25:00"},
+		{90 * time.Second, "01:30"},
+		{1500 * time.Millisecond, "00:02"},
+		{0, "00:00"},
+		{-time.Second, "00:00"},
+	}
+
+	for _, tt := range tests {
+		result := formatCountdown(tt.duration)
+		if result != tt.expected {
+			t.Errorf("formatCountdown(%v) = %q; want %q", tt.duration, result, tt.expected)
+		}
+	}
+}
+
+func TestRunTimerCompletes(t *testing.T) {
+	var out bytes.Buffer
+	stop := make(chan os.Signal, 1)
+
+	if runTimer(&out, "🍅", 10*time.Millisecond, stop) != nil {
+		t.Fatal("expected the timer to complete")
+	}
+	if !strings.Contains(out.String(), "00:00") {
+		t.Errorf("expected the final countdown to be printed, got %q", out.String())
+	}
+}
+
+func TestRunTimerInterrupted(t *testing.T) {
+	var out bytes.Buffer
+	stop := make(chan os.Signal, 1)
+	stop <- os.Interrupt
+
+	if runTimer(&out, "🍅", time.Minute, stop) != os.Interrupt {
+		t.Fatal("expected the timer to be interrupted")
+	}
+}
+
+func TestStartWithoutTask(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	handleStartCommand([]string{"-d", "10ms"})
+
+	var sessions, tracking int
+	if err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM sessions), (SELECT COUNT(*) FROM task_tracking)`).Scan(&sessions, &tracking); err != nil {
+		t.Fatalf("failed to count rows: %v", err)
+	}
+	if sessions != 0 || tracking != 0 {
+		t.Errorf("expected a plain timer to record nothing, got %d sessions and %d tracking rows", sessions, tracking)
+	}
+}
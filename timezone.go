@@ -0,0 +1,135 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	// the timezone setting must work on systems without a zoneinfo database
+	_ "time/tzdata"
+)
+
+// Timestamps are stored in UTC as 2006-01-02 15:04:05, the format of SQLite's
+// datetime('now'), so they sort and compare as plain strings. Days are local:
+// they are turned into a range of stored timestamps before querying.
+const storedTimeLayout = "2006-01-02 15:04:05"
+
+// formatStoredTime formats a point in time the way it is stored
+func formatStoredTime(t time.Time) string {
+	return t.UTC().Format(storedTimeLayout)
+}
+
+// parseStoredTime reads a timestamp as written by any version of tomatillo.
+// Timestamps with an offset are exact; those without one are read in naive,
+// UTC for the current format and the local time zone for older rows.
+func parseStoredTime(value string, naive *time.Location) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05Z07:00", "2006-01-02T15:04:05Z07:00"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range []string{storedTimeLayout, "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, naive); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", value)
+}
+
+// storedDayRange turns a range of local days (inclusive, formatted 2006-01-02)
+// into the stored timestamps it spans, to be queried as >= start AND < end. A day
+// is not always 24 hours long: the range follows daylight saving time.
+func storedDayRange(from, to string) (string, string, error) {
+	start, err := time.ParseInLocation("2006-01-02", from, time.Local)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid date %q, expected YYYY-MM-DD", from)
+	}
+	end, err := time.ParseInLocation("2006-01-02", to, time.Local)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid date %q, expected YYYY-MM-DD", to)
+	}
+	return formatStoredTime(start), formatStoredTime(end.AddDate(0, 0, 1)), nil
+}
+
+func validateTimezone(value string) error {
+	if _, err := time.LoadLocation(value); err != nil {
+		return fmt.Errorf("expected a time zone such as Europe/Paris or UTC, got %q", value)
+	}
+	return nil
+}
+
+// loadTimezone reads the timezone setting into time.Local. Everything is stored
+// in UTC, so the setting only changes how days are split and times are shown.
+func loadTimezone(db *sql.DB) error {
+	value, err := getConfig(db, "timezone")
+	if err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(value)
+	if err != nil {
+		return err
+	}
+	time.Local = loc
+	return nil
+}
+
+// timestampColumns lists every column that holds a point in time
+var timestampColumns = []struct{ table, column string }{
+	{"tasks", "created_at"},
+	{"tasks", "updated_at"},
+	{"projects", "created_at"},
+	{"sessions", "started_at"},
+	{"sessions", "ended_at"},
+	{"breaks", "started_at"},
+	{"breaks", "ended_at"},
+	{"task_tracking", "started_at"},
+	{"task_tracking", "ended_at"},
+	{"schema_version", "applied_at"},
+}
+
+// convertTimestampsToUTC rewrites every timestamp in the stored format. Task and
+// project times were written in local time without an offset and are read in
+// the time zone of the machine running the migration, which is the one SQLite
+// used to write them. Values it cannot read are left as they are.
+//
+// SQLite cannot change the default of an existing column, so the localtime
+// defaults of tasks and projects stay in the schema. Every insert sets its
+// timestamps instead of relying on them.
+func convertTimestampsToUTC(tx *sql.Tx) error {
+	type stored struct {
+		rowid int64
+		value string
+	}
+
+	for _, c := range timestampColumns {
+		rows, err := tx.Query(fmt.Sprintf(`SELECT rowid, CAST(%s AS TEXT) FROM %s WHERE %s IS NOT NULL`, c.column, c.table, c.column))
+		if err != nil {
+			return err
+		}
+		var values []stored
+		for rows.Next() {
+			var s stored
+			if err := rows.Scan(&s.rowid, &s.value); err != nil {
+				rows.Close()
+				return err
+			}
+			values = append(values, s)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, s := range values {
+			t, err := parseStoredTime(s.value, time.Local)
+			if err != nil {
+				continue
+			}
+			query := fmt.Sprintf(`UPDATE %s SET %s = ? WHERE rowid = ?`, c.table, c.column)
+			if _, err := tx.Exec(query, formatStoredTime(t), s.rowid); err != nil {
+				return fmt.Errorf("failed to convert %s.%s: %v", c.table, c.column, err)
+			}
+		}
+	}
+	return nil
+}
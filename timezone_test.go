@@ -0,0 +1,183 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// pinTimezone makes the named zone the local one until the returned function
+// restores the previous one
+func pinTimezone(t *testing.T, name string) func() {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("failed to load %s: %v", name, err)
+	}
+	previous := time.Local
+	time.Local = loc
+	return func() { time.Local = previous }
+}
+
+func TestParseStoredTime(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("failed to load America/New_York: %v", err)
+	}
+	want := time.Date(2026, time.March, 8, 14, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		naive *time.Location
+	}{
+		{"2026-03-08 14:30:00", time.UTC},
+		{"2026-03-08 10:30:00", newYork},
+		{"2026-03-08 10:30:00-04:00", time.UTC},
+		{"2026-03-08 09:30:00.000000000-05:00", newYork},
+		{"2026-03-08T14:30:00Z", newYork},
+	}
+	for _, tt := range tests {
+		got, err := parseStoredTime(tt.value, tt.naive)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("parseStoredTime(%q) = %v (%v), expected %v", tt.value, got, err, want)
+		}
+	}
+	if _, err := parseStoredTime("yesterday", time.UTC); err == nil {
+		t.Error("expected an error for a value that is not a timestamp")
+	}
+}
+
+func TestStoredDayRangeAcrossDST(t *testing.T) {
+	defer pinTimezone(t, "America/New_York")()
+
+	tests := []struct {
+		day, start, end string
+	}{
+		{"2026-03-07", "2026-03-07 05:00:00", "2026-03-08 05:00:00"},
+		{"2026-03-08", "2026-03-08 05:00:00", "2026-03-09 04:00:00"}, // 23 hours
+		{"2026-11-01", "2026-11-01 04:00:00", "2026-11-02 05:00:00"}, // 25 hours
+	}
+	for _, tt := range tests {
+		start, end, err := storedDayRange(tt.day, tt.day)
+		if err != nil || start != tt.start || end != tt.end {
+			t.Errorf("storedDayRange(%s) = %s to %s (%v), expected %s to %s", tt.day, start, end, err, tt.start, tt.end)
+		}
+	}
+	if _, _, err := storedDayRange("2026-3-8", "2026-03-08"); err == nil {
+		t.Error("expected an error for a malformed date")
+	}
+}
+
+func TestDayBucketingAcrossDST(t *testing.T) {
+	defer pinTimezone(t, "America/New_York")()
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	// clocks go forward at 2am on 2026-03-08, so the day ends at 04:00 UTC
+	created := []struct {
+		name string
+		at   time.Time
+	}{
+		{"Saturday night", time.Date(2026, time.March, 7, 23, 30, 0, 0, time.Local)},
+		{"Sunday morning", time.Date(2026, time.March, 8, 3, 30, 0, 0, time.Local)},
+		{"Sunday night", time.Date(2026, time.March, 8, 23, 30, 0, 0, time.Local)},
+		{"Monday", time.Date(2026, time.March, 9, 0, 30, 0, 0, time.Local)},
+	}
+	for _, task := range created {
+		_, err := db.Exec(`INSERT INTO tasks (name, estimate, created_at, updated_at) VALUES (?, 1, ?, ?)`,
+			task.name, formatStoredTime(task.at), formatStoredTime(task.at))
+		if err != nil {
+			t.Fatalf("failed to add task: %v", err)
+		}
+	}
+
+	tasks, err := getTasksCreatedBetween(db, "2026-03-08", "2026-03-08")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tasks) != 2 || tasks[0].Name != "Sunday morning" || tasks[1].Name != "Sunday night" {
+		t.Fatalf("expected the two Sunday tasks, got %+v", tasks)
+	}
+	if tasks[1].CreatedAt.Hour() != 23 || tasks[1].CreatedAt.Day() != 8 {
+		t.Errorf("expected the creation time in local time, got %v", tasks[1].CreatedAt)
+	}
+
+	breakdown, err := getProjectBreakdown(db, "2026-03-09", "2026-03-09", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(breakdown) != 1 || breakdown[0].Tasks != 1 {
+		t.Errorf("expected 1 task on Monday, got %+v", breakdown)
+	}
+
+	// a pomodoro late on Sunday evening is in UTC on Monday, but counts on Sunday
+	start := time.Date(2026, time.March, 8, 22, 0, 0, 0, time.Local)
+	id, err := startSession(db, 3, 25*time.Minute, start)
+	if err != nil {
+		t.Fatalf("failed to start session: %v", err)
+	}
+	if _, err := endSession(db, id, start.Add(25*time.Minute), "completed", "", ""); err != nil {
+		t.Fatalf("failed to end session: %v", err)
+	}
+	for day, want := range map[string]int{"2026-03-08": 1, "2026-03-09": 0} {
+		count, err := countPomodorosSinceLongBreak(db, day)
+		if err != nil || count != want {
+			t.Errorf("expected %d pomodoros on %s, got %d (%v)", want, day, count, err)
+		}
+	}
+}
+
+func TestConvertTimestampsToUTC(t *testing.T) {
+	defer pinTimezone(t, "America/New_York")()
+	db = openDatabase(":memory:")
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	// the schema as it was at version 10
+	if _, err := db.Exec(schemaVersionTable); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	for _, m := range migrations[:10] {
+		if err := m.up(tx); err != nil {
+			t.Fatalf("migration %d failed: %v", m.version, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	// before UTC storage, task times were local without an offset and session
+	// times were written by the driver with the offset of the machine
+	_, err = db.Exec(`INSERT INTO tasks (name, estimate, created_at, updated_at) VALUES ('Legacy', 1, '2026-03-08 23:30:00', '2026-11-01 12:00:00')`)
+	if err != nil {
+		t.Fatalf("failed to add legacy task: %v", err)
+	}
+	started := time.Date(2026, time.March, 8, 22, 0, 0, 0, time.Local)
+	if _, err := db.Exec(`INSERT INTO sessions (task_id, started_at, planned_seconds) VALUES (1, ?, 1500)`, started); err != nil {
+		t.Fatalf("failed to add legacy session: %v", err)
+	}
+
+	if tx, err = db.Begin(); err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	if err := convertTimestampsToUTC(tx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	var createdAt, updatedAt, startedAt string
+	err = db.QueryRow(`
+    SELECT CAST(t.created_at AS TEXT), CAST(t.updated_at AS TEXT), CAST(s.started_at AS TEXT)
+    FROM tasks t JOIN sessions s ON s.task_id = t.id`).Scan(&createdAt, &updatedAt, &startedAt)
+	if err != nil {
+		t.Fatalf("failed to read converted timestamps: %v", err)
+	}
+	if createdAt != "2026-03-09 03:30:00" || updatedAt != "2026-11-01 17:00:00" || startedAt != "2026-03-09 02:00:00" {
+		t.Errorf("unexpected converted timestamps: %s, %s, %s", createdAt, updatedAt, startedAt)
+	}
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Keys the ui reacts to besides plain characters
+const (
+	keyUp        = "up"
+	keyDown      = "down"
+	keyEnter     = "enter"
+	keyEscape    = "escape"
+	keyBackspace = "backspace"
+)
+
+// tui is the state of 'tomatillo ui' between two key presses
+type tui struct {
+	tasks    []Task
+	cursor   int
+	duration time.Duration
+	session  *uiSession
+	prompt   *uiPrompt
+	message  string
+	bell     bool
+	quit     bool
+}
+
+// uiSession is the pomodoro running in the ui
+type uiSession struct {
+	id       int
+	taskID   int
+	name     string
+	deadline time.Time
+}
+
+// uiPrompt asks for a line of text, or a single y/n key when confirm is set
+type uiPrompt struct {
+	label   string
+	input   string
+	confirm bool
+	submit  func(input string)
+}
+
+// Helper function to handle the 'ui' command. It takes over the terminal until
+// q is pressed, so output from the helpers it calls is silenced meanwhile.
+// A running pomodoro is kept in the sessions table, so quitting does not lose it.
+func handleUICommand(args []string) {
+	uiFlag := flag.NewFlagSet("ui", flag.ExitOnError)
+	duration := uiFlag.Duration("duration", defaultPomodoroDuration, "Length of the pomodoros started from the ui (or use -d)")
+	uiFlag.DurationVar(duration, "d", defaultPomodoroDuration, "Length of the pomodoros (short version)")
+	uiFlag.Parse(args)
+
+	if *duration <= 0 {
+		log.Println("Please provide a positive duration.")
+		os.Exit(1)
+	}
+
+	restore, err := enableCbreak()
+	if err != nil {
+		log.Fatalf("the ui needs an interactive terminal: %v", err)
+	}
+
+	terminal := os.Stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		restore()
+		log.Fatal(err)
+	}
+	os.Stdout = devNull
+
+	ui := &tui{duration: *duration}
+	ui.refresh()
+
+	// alternate screen, hidden cursor
+	fmt.Fprint(terminal, "\033[?1049h\033[?25l")
+	defer func() {
+		fmt.Fprint(terminal, "\033[?25h\033[?1049l")
+		os.Stdout = terminal
+		devNull.Close()
+		restore()
+		if ui.session != nil {
+			fmt.Printf("The pomodoro on %s is still running, see 'tomatillo status'.\n", ui.session.name)
+		}
+	}()
+
+	keys := make(chan string)
+	go readKeys(os.Stdin, keys)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var stopped os.Signal
+	for !ui.quit {
+		renderUI(terminal, ui, clock.Now())
+
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				ui.quit = true
+				break
+			}
+			ui.handleKey(key)
+		case <-ticker.C:
+			ui.tick(clock.Now())
+			ui.refresh()
+		case stopped = <-signals:
+			ui.quit = true
+		}
+	}
+
+	// like 'start', Ctrl-C gives up the pomodoro while quitting or closing the
+	// terminal leaves it running for 'status', 'resume' and 'stop'
+	if stopped == os.Interrupt || stopped == syscall.SIGTERM {
+		ui.abandon()
+	}
+}
+
+// enableCbreak switches the terminal to reading single key presses without echo
+// and returns a function that puts the previous settings back
+func enableCbreak() (func(), error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return nil, err
+	}
+	return func() { stty(strings.TrimSpace(saved)) }, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// readKeys sends every key press read from r until it is closed
+func readKeys(r io.Reader, keys chan<- string) {
+	buf := make([]byte, 16)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		keys <- decodeKey(buf[:n])
+	}
+}
+
+// decodeKey names the key behind the bytes of a single read
+func decodeKey(b []byte) string {
+	switch string(b) {
+	case "\033[A", "\033OA":
+		return keyUp
+	case "\033[B", "\033OB":
+		return keyDown
+	case "\r", "\n":
+		return keyEnter
+	case "\033":
+		return keyEscape
+	case "\x7f", "\b":
+		return keyBackspace
+	}
+	return string(b)
+}
+
+// refresh reloads today's tasks and keeps the cursor on the list. It also picks
+// up a pomodoro started or stopped from another shell.
+func (ui *tui) refresh() {
+	tasks, err := getDailyTasks(db, clock.Now())
+	if err != nil {
+		ui.message = err.Error()
+		return
+	}
+	ui.tasks = tasks
+
+	session, err := getRunningSession(db)
+	switch {
+	case err == sql.ErrNoRows:
+		ui.session = nil
+	case err != nil:
+		ui.message = err.Error()
+	case ui.session == nil || ui.session.id != session.ID:
+		// a pomodoro left open for longer than it was planned is for 'stop' to settle
+		if clock.Now().Before(session.Deadline()) {
+			ui.session = &uiSession{id: session.ID, taskID: session.TaskID, name: session.TaskName, deadline: session.Deadline()}
+		}
+	}
+	if ui.cursor >= len(ui.tasks) {
+		ui.cursor = len(ui.tasks) - 1
+	}
+	if ui.cursor < 0 {
+		ui.cursor = 0
+	}
+}
+
+// selected returns the task under the cursor
+func (ui *tui) selected() (Task, bool) {
+	if len(ui.tasks) == 0 {
+		return Task{}, false
+	}
+	return ui.tasks[ui.cursor], true
+}
+
+// tick completes the running pomodoro once its time is up. The caller reloads
+// the tasks afterwards, which also picks up changes made from other shells.
+func (ui *tui) tick(now time.Time) {
+	if ui.session == nil || now.Before(ui.session.deadline) {
+		return
+	}
+
+	completed, err := completePomodoro(ui.session.id, ui.session.taskID, now)
+	switch {
+	case err != nil:
+		ui.message = err.Error()
+	case completed:
+		ui.message = fmt.Sprintf("Pomodoro on %s completed, time for a break.", ui.session.name)
+		ui.bell = true
+	default:
+		ui.message = fmt.Sprintf("Pomodoro on %s was interrupted, the actual count was not updated.", ui.session.name)
+	}
+	ui.session = nil
+}
+
+// abandon gives up the running pomodoro, if any
+func (ui *tui) abandon() {
+	if ui.session == nil {
+		return
+	}
+	if _, err := endSession(db, ui.session.id, clock.Now(), "abandoned", "", ""); err != nil {
+		ui.message = err.Error()
+		return
+	}
+	ui.message = fmt.Sprintf("Pomodoro on %s abandoned.", ui.session.name)
+	ui.session = nil
+}
+
+func (ui *tui) handleKey(key string) {
+	if ui.prompt != nil {
+		ui.handlePromptKey(key)
+		return
+	}
+
+	ui.message = ""
+	task, ok := ui.selected()
+
+	switch key {
+	case keyUp, "k":
+		if ui.cursor > 0 {
+			ui.cursor--
+		}
+	case keyDown, "j":
+		if ui.cursor < len(ui.tasks)-1 {
+			ui.cursor++
+		}
+	case keyEnter, "s":
+		if !ok {
+			return
+		}
+		if ui.session != nil {
+			ui.message = "A pomodoro is already running, press c to cancel it first."
+			return
+		}
+		if _, err := getRunningSession(db); err != sql.ErrNoRows {
+			ui.message = "A pomodoro is already running, run 'tomatillo stop' to settle it first."
+			return
+		}
+		now := clock.Now()
+		id, err := beginPomodoro(task.ID, ui.duration, now)
+		if err != nil {
+			ui.message = err.Error()
+			return
+		}
+		ui.session = &uiSession{id: id, taskID: task.ID, name: task.Name, deadline: now.Add(ui.duration)}
+	case "c":
+		if ui.session == nil {
+			ui.message = "No pomodoro is running."
+			return
+		}
+		ui.abandon()
+	case "d":
+		if !ok {
+			return
+		}
+		ui.report(markAsDone(task.ID, clock.Now()), fmt.Sprintf("Marked %s as done.", task.Name))
+	case "e":
+		if !ok {
+			return
+		}
+		ui.ask(fmt.Sprintf("Estimate for %s: ", task.Name), func(input string) {
+			estimate, err := strconv.Atoi(input)
+			if err != nil || estimate < 0 {
+				ui.message = fmt.Sprintf("Invalid estimate %q.", input)
+				return
+			}
+			ui.report(updateEstimate(task.ID, estimate, clock.Now()), fmt.Sprintf("Estimate of %s set to %d.", task.Name, estimate))
+		})
+	case "a":
+		ui.ask("Name: ", func(name string) {
+			if name == "" {
+				return
+			}
+			ui.ask("Estimate [1]: ", func(input string) {
+				estimate := 1
+				if input != "" {
+					var err error
+					if estimate, err = strconv.Atoi(input); err != nil || estimate < 0 {
+						ui.message = fmt.Sprintf("Invalid estimate %q.", input)
+						return
+					}
+				}
+				_, err := createTask(db, Task{Name: name, Estimate: estimate}, clock.Now())
+				ui.report(err, fmt.Sprintf("Added %s.", name))
+				if err == nil {
+					ui.cursor = len(ui.tasks) - 1
+				}
+			})
+		})
+	case "x":
+		if !ok {
+			return
+		}
+		if ui.session != nil && ui.session.taskID == task.ID {
+			ui.message = "Cancel the running pomodoro before deleting its task."
+			return
+		}
+		ui.confirm(fmt.Sprintf("Delete %s? (y/n) ", task.Name), func() {
+			ui.report(deleteTask(db, task.ID), fmt.Sprintf("Deleted %s.", task.Name))
+		})
+	case "q":
+		ui.quit = true
+	}
+}
+
+// handlePromptKey edits the text of the open prompt
+func (ui *tui) handlePromptKey(key string) {
+	prompt := ui.prompt
+	if prompt.confirm {
+		ui.prompt = nil
+		if key == "y" || key == "Y" {
+			prompt.submit("y")
+		}
+		return
+	}
+
+	switch key {
+	case keyEscape:
+		ui.prompt = nil
+	case keyEnter:
+		ui.prompt = nil
+		prompt.submit(strings.TrimSpace(prompt.input))
+	case keyBackspace:
+		if runes := []rune(prompt.input); len(runes) > 0 {
+			prompt.input = string(runes[:len(runes)-1])
+		}
+	case keyUp, keyDown:
+	default:
+		prompt.input += key
+	}
+}
+
+func (ui *tui) ask(label string, submit func(input string)) {
+	ui.prompt = &uiPrompt{label: label, submit: submit}
+}
+
+func (ui *tui) confirm(label string, yes func()) {
+	ui.prompt = &uiPrompt{label: label, confirm: true, submit: func(string) { yes() }}
+}
+
+// report shows the outcome of an action and reloads the tasks
+func (ui *tui) report(err error, success string) {
+	if err != nil {
+		ui.message = err.Error()
+	} else {
+		ui.message = success
+	}
+	ui.refresh()
+}
+
+// renderUI draws a whole frame at once so the screen does not flicker
+func renderUI(w io.Writer, ui *tui, now time.Time) {
+	var frame bytes.Buffer
+	if ui.bell {
+		frame.WriteString("\a")
+		ui.bell = false
+	}
+	frame.WriteString("\033[H\033[2J")
+
+	fmt.Fprintf(&frame, "🍅 tomatillo · %s\n\n", now.Format("Monday 2006-01-02 15:04"))
+	fmt.Fprintln(&frame, "╔════════════════════════════════════════════════════════════════════════════════════╗")
+	fmt.Fprintln(&frame, "║            00|01|02|03|04|05|06|07|08|09|10|11|12|13|14|15|16|17|18|19|20|21|22|23 ║")
+	writeDailyBlock(&frame, formatDate(now), reportOptions{now: now})
+	fmt.Fprintln(&frame, "╚════════════════════════════════════════════════════════════════════════════════════╝")
+	fmt.Fprintln(&frame)
+
+	fmt.Fprintf(&frame, "  %-4s %-5s %-40s %-4s %-4s\n", "ID", "Done?", "Task", "Est.", "Act.")
+	if len(ui.tasks) == 0 {
+		fmt.Fprintln(&frame, "  No tasks for today yet, press a to add one.")
+	}
+	for i, task := range ui.tasks {
+		done := "[ ]"
+		if task.Done {
+			done = "[x]"
+		}
+		name := task.Name
+		if len([]rune(name)) > 40 {
+			name = string([]rune(name)[:39]) + "…"
+		}
+		row := fmt.Sprintf("%-4d %-5s %-40s %-4d %-4d", task.ID, done, name, task.Estimate, task.Actual)
+		if i == ui.cursor {
+			fmt.Fprintf(&frame, "> \033[7m%s\033[0m\n", row)
+		} else {
+			fmt.Fprintf(&frame, "  %s\n", row)
+		}
+	}
+	fmt.Fprintln(&frame)
+
+	if ui.session != nil {
+		fmt.Fprintf(&frame, "🍅 %s  %s\n", formatCountdown(ui.session.deadline.Sub(now)), ui.session.name)
+	} else {
+		fmt.Fprintln(&frame, "No pomodoro running.")
+	}
+	if ui.prompt != nil {
+		fmt.Fprintf(&frame, "%s%s\n", ui.prompt.label, ui.prompt.input)
+	} else {
+		fmt.Fprintln(&frame, ui.message)
+	}
+	fmt.Fprintln(&frame)
+	fmt.Fprintln(&frame, "↑/↓ select · enter start · c cancel · d done · e estimate · a add · x delete · q quit")
+
+	w.Write(frame.Bytes())
+}
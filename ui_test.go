@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// typeKeys feeds every character of text to the ui as a key press
+func typeKeys(ui *tui, text string) {
+	for _, r := range text {
+		ui.handleKey(string(r))
+	}
+}
+
+func TestDecodeKey(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"\033[A", keyUp},
+		{"\033[B", keyDown},
+		{"\r", keyEnter},
+		{"\033", keyEscape},
+		{"\x7f", keyBackspace},
+		{"q", "q"},
+	}
+
+	for _, tt := range tests {
+		if result := decodeKey([]byte(tt.input)); result != tt.expected {
+			t.Errorf("decodeKey(%q) = %q; want %q", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestUIEditsTasks(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	ui := &tui{duration: time.Minute}
+	ui.refresh()
+
+	ui.handleKey("a")
+	typeKeys(ui, "Write testz")
+	ui.handleKey(keyBackspace)
+	typeKeys(ui, "s")
+	ui.handleKey(keyEnter)
+	typeKeys(ui, "3")
+	ui.handleKey(keyEnter)
+
+	ui.handleKey("a")
+	typeKeys(ui, "Fix login")
+	ui.handleKey(keyEnter)
+	ui.handleKey(keyEnter)
+
+	if len(ui.tasks) != 2 || ui.tasks[0].Name != "Write tests" || ui.tasks[0].Estimate != 3 || ui.tasks[1].Estimate != 1 {
+		t.Fatalf("expected the two added tasks, got %+v", ui.tasks)
+	}
+	if ui.cursor != 1 {
+		t.Errorf("expected the cursor on the added task, got %d", ui.cursor)
+	}
+
+	ui.handleKey(keyUp)
+	ui.handleKey("e")
+	typeKeys(ui, "5")
+	ui.handleKey(keyEnter)
+	ui.handleKey("d")
+	if task := ui.tasks[0]; task.Estimate != 5 || !task.Done {
+		t.Errorf("expected the estimate to be 5 and the task done, got %+v", task)
+	}
+
+	ui.handleKey("x")
+	ui.handleKey("n")
+	if len(ui.tasks) != 2 {
+		t.Fatalf("expected the task to be kept when the delete is not confirmed, got %d tasks", len(ui.tasks))
+	}
+	ui.handleKey("x")
+	ui.handleKey("y")
+	if len(ui.tasks) != 1 || ui.tasks[0].Name != "Fix login" {
+		t.Errorf("expected only Fix login to be left, got %+v", ui.tasks)
+	}
+
+	var frame bytes.Buffer
+	renderUI(&frame, ui, time.Now())
+	if !strings.Contains(frame.String(), "Fix login") || !strings.Contains(frame.String(), "Deleted Write tests.") {
+		t.Errorf("expected the frame to show the task and the last action, got %q", frame.String())
+	}
+}
+
+func TestUIRunsPomodoro(t *testing.T) {
+	db = initializeDatabase(":memory:")
+	defer db.Close()
+
+	if _, err := createTask(db, Task{Name: "Write tests", Estimate: 2}, time.Now()); err != nil {
+		t.Fatalf("failed to add task: %v", err)
+	}
+	ui := &tui{duration: time.Minute}
+	ui.refresh()
+
+	ui.handleKey(keyEnter)
+	if ui.session == nil {
+		t.Fatal("expected a pomodoro to be running")
+	}
+	ui.handleKey("s")
+	if !strings.Contains(ui.message, "already running") {
+		t.Errorf("expected a second pomodoro to be refused, got %q", ui.message)
+	}
+
+	var frame bytes.Buffer
+	renderUI(&frame, ui, ui.session.deadline.Add(-90*time.Second))
+	if !strings.Contains(frame.String(), "🍅 01:30  Write tests") {
+		t.Errorf("expected the countdown in the frame, got %q", frame.String())
+	}
+
+	ui.tick(ui.session.deadline.Add(time.Second))
+	ui.refresh()
+	if ui.session != nil || ui.tasks[0].Actual != 1 {
+		t.Errorf("expected the pomodoro to be completed and counted, got %+v", ui.tasks[0])
+	}
+
+	ui.handleKey(keyEnter)
+	ui.handleKey("c")
+	ui.refresh()
+	if ui.session != nil || ui.tasks[0].Actual != 1 {
+		t.Errorf("expected the cancelled pomodoro not to be counted, got %+v", ui.tasks[0])
+	}
+}
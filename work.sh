@@ -4,18 +4,10 @@ work() {
   duration="${1:-25m}"
   task_id="$2"
 
-  if [ -n "$task_id" ]; then
-    tomatillo activate --id="$task_id"
-  fi
-
-  timer "$duration" && echo -e "\a"
-  # Update the tomatillo task if a task id is provided
-  if [ -n "$task_id" ]; then
-    tomatillo update --id="$task_id"
-  fi
+  tomatillo start --duration="$duration" ${task_id:+--id="$task_id"}
 }
 
 rest() {
-  # usage: rest 10m, rest 60s etc. Default is 5m
-  timer "${1:-5m}" && echo -e "\a"
+  # usage: rest, rest 10m, rest 60s etc. Defaults to the configured break length
+  tomatillo break ${1:+--duration="$1"}
 }
\ No newline at end of file